@@ -0,0 +1,94 @@
+// 既存の画像ディレクトリを、内容のハッシュで管理する画像ストアに取り込む
+//
+// ディレクトリからの相対パスを画像のパス（products.image）として登録する。
+// 同じ内容の画像は1つだけ保存する。保存先と DB はサーバーと同じ設定（環境変数 IMAGE_STORE や設定ファイル）で指定する。
+//
+//	IMAGE_STORE=fs IMAGE_STORE_DIR=/app/image-store go run ./cmd/image-import -dir /app/images
+package main
+
+import (
+	"backend/internal/config"
+	"backend/internal/db"
+	"backend/internal/imagestore"
+	"backend/internal/logging"
+	"backend/internal/repository"
+	"context"
+	"flag"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	dir := flag.String("dir", "/app/images", "directory to import")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fatal("Failed to load config", "error", err)
+	}
+	logging.Setup(cfg.Log)
+
+	backend, err := imagestore.NewBackend(cfg.ImageStore)
+	if err != nil {
+		fatal("Failed to configure image store", "error", err)
+	}
+	if backend == nil {
+		fatal("image_store.kind (IMAGE_STORE) is not set (want fs or s3)")
+	}
+
+	dbConn, err := db.InitDBConnection(cfg.Database, false)
+	if err != nil {
+		fatal("Failed to connect to database", "error", err)
+	}
+	defer dbConn.Close()
+
+	store := imagestore.New(backend, repository.NewImageRepository(dbConn), 0)
+	ctx := context.Background()
+
+	var files, unique int
+	var totalBytes, storedBytes int64
+	err = filepath.WalkDir(*dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(*dir, path)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		result, err := store.Import(ctx, rel, f, imagestore.ContentTypeByExt(filepath.Ext(path)))
+		if err != nil {
+			return err
+		}
+		files++
+		totalBytes += result.Size
+		if !result.Deduplicated {
+			unique++
+			storedBytes += result.Size
+		}
+		if files%1000 == 0 {
+			slog.Info("Importing images", "files", files, "unique", unique)
+		}
+		return nil
+	})
+	if err != nil {
+		fatal("Failed to import images", "error", err)
+	}
+
+	slog.Info("Imported images", "files", files, "new_blobs", unique,
+		"stored_bytes", storedBytes, "deduplicated_bytes", totalBytes-storedBytes)
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
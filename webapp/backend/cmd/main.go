@@ -1,18 +1,80 @@
 package main
 
 import (
+	"backend/internal/config"
+	"backend/internal/logging"
 	"backend/internal/server"
-	"log"
+	"backend/internal/telemetry"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	// 停止時にバックグラウンドの処理の終了を待つ時間
+	workerStopTimeout = 10 * time.Second
+	// 停止時に残りのトレースの送信を待つ時間
+	tracerFlushTimeout = 5 * time.Second
 )
 
 func main() {
-	srv, dbConn, err := server.NewServer()
+	if err := run(); err != nil {
+		slog.Error("Server exited with error", "error", err)
+		os.Exit(1)
+	}
+}
+
+// os.Exit では defer が実行されないため、後始末は run の中で行う
+// 停止の順序: 新しい接続を止めて処理中のリクエストを待つ → トレースを送信する → DB を閉じる
+func run() error {
+	printConfig := flag.Bool("print-config", false, "print the effective config (secrets redacted) and exit")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
 	if err != nil {
-		log.Fatalf("Failed to initialize server: %v", err)
+		return err
 	}
-	if dbConn != nil {
-		defer dbConn.Close()
+	if *printConfig {
+		return cfg.Print(os.Stdout)
 	}
+	logging.Setup(cfg.Log)
 
-	srv.Run()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// otelchi などはトレーサーを作成時に取得するため、サーバーより先に初期化する
+	shutdownTracer, err := telemetry.Init(ctx, cfg.Telemetry)
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracer: %w", err)
+	}
+	flushTracer := func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), tracerFlushTimeout)
+		defer cancel()
+		if err := shutdownTracer(flushCtx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}
+
+	srv, dbConn, err := server.NewServer(ctx, cfg)
+	if err != nil {
+		flushTracer()
+		return fmt.Errorf("failed to initialize server: %w", err)
+	}
+
+	runErr := srv.Run(ctx)
+
+	// 起動に失敗した場合もバックグラウンドの処理を止める
+	stop()
+	if !srv.Wait(workerStopTimeout) {
+		slog.Warn("Background workers did not stop in time", "timeout", workerStopTimeout)
+	}
+
+	flushTracer()
+	if err := dbConn.Close(); err != nil {
+		slog.Error("Failed to close database", "error", err)
+	}
+	return runErr
 }
@@ -0,0 +1,75 @@
+// ローカルでの動作確認用の Webhook 受信サーバー
+//
+// 受信したリクエストの署名を検証し、内容をログに書き出す。
+// -fail-rate を指定すると一定の割合で 500 を返し、再送の動作を確認できる。
+//
+//	go run ./cmd/webhook-receiver -addr :9090 -secret <登録時の secret>
+package main
+
+import (
+	"backend/internal/webhook"
+	"flag"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":9090", "listen address")
+	secret := flag.String("secret", "", "endpoint secret used to verify signatures")
+	failRate := flag.Float64("fail-rate", 0, "fraction of requests to answer with 500 (0-1)")
+	tolerance := flag.Duration("tolerance", 5*time.Minute, "maximum allowed clock skew of the timestamp")
+	flag.Parse()
+
+	if *secret == "" {
+		fatal("-secret is required")
+	}
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		timestamp, err := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+		if err != nil {
+			slog.Warn("Rejected webhook with an invalid timestamp", "timestamp", r.Header.Get(webhook.HeaderTimestamp))
+			http.Error(w, "invalid timestamp", http.StatusBadRequest)
+			return
+		}
+		if skew := time.Since(time.Unix(timestamp, 0)); skew > *tolerance || skew < -*tolerance {
+			slog.Warn("Rejected webhook with a timestamp out of tolerance", "skew", skew)
+			http.Error(w, "timestamp out of tolerance", http.StatusBadRequest)
+			return
+		}
+		if !webhook.Verify(*secret, timestamp, body, r.Header.Get(webhook.HeaderSignature)) {
+			slog.Warn("Rejected webhook with a signature mismatch", "id", r.Header.Get(webhook.HeaderID))
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		if rand.Float64() < *failRate {
+			slog.Info("Simulated failure", "id", r.Header.Get(webhook.HeaderID))
+			http.Error(w, "simulated failure", http.StatusInternalServerError)
+			return
+		}
+
+		slog.Info("Received webhook", "event", r.Header.Get(webhook.HeaderEvent), "id", r.Header.Get(webhook.HeaderID), "body", string(body))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	slog.Info("Listening", "addr", *addr)
+	if err := http.ListenAndServe(*addr, nil); err != nil {
+		fatal("Server stopped", "error", err)
+	}
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
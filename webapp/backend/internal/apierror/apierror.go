@@ -0,0 +1,77 @@
+// API のエラーレスポンス
+//
+// エラーはすべて {"code", "message", "details", "request_id"} の JSON で返す。
+// クライアントは message ではなく code で判別する（code は変更しない）。
+package apierror
+
+import (
+	"backend/internal/logging"
+	"encoding/json"
+	"net/http"
+)
+
+// エラーコード
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeNotAcceptable      = "not_acceptable"
+	CodeInternal           = "internal_error"
+
+	CodeProductNotFound         = "product_not_found"
+	CodeImageNotFound           = "image_not_found"
+	CodeOrderNotFound           = "order_not_found"
+	CodeOrderGroupNotFound      = "order_group_not_found"
+	CodeOrderNotCancellable     = "order_not_cancellable"
+	CodeOrderCancelled          = "order_cancelled"
+	CodeDeliveryPlanConflict    = "delivery_plan_conflict"
+	CodeCartItemNotFound        = "cart_item_not_found"
+	CodeCartEmpty               = "cart_empty"
+	CodeWebhookEndpointNotFound = "webhook_endpoint_not_found"
+	CodeIdempotencyKeyInUse     = "idempotency_key_in_use"
+	CodeIdempotencyKeyMismatch  = "idempotency_key_mismatch"
+)
+
+type Error struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// 項目ごとのエラーなど、code に応じた補足情報
+	Details any `json:"details,omitempty"`
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// details を付けたコピーを返す
+func (e *Error) WithDetails(details any) *Error {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+type body struct {
+	*Error
+	RequestID string `json:"request_id"`
+}
+
+// エラーを JSON で書き込む
+func WriteError(w http.ResponseWriter, r *http.Request, e *Error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(body{Error: e, RequestID: logging.RequestID(r.Context())})
+}
+
+func Write(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	WriteError(w, r, New(status, code, message))
+}
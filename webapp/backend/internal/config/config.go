@@ -0,0 +1,161 @@
+// バックエンドの設定
+//
+// 値は次の順に読み込み、後のものほど優先する。
+//
+//  1. 既定値（各フィールドの default タグ）
+//  2. 設定ファイル（-config または環境変数 CONFIG_FILE。拡張子が .yaml / .yml / .toml のもの）
+//  3. 環境変数（env タグ。複数ある場合は先に設定されているもの）
+//  4. コマンドラインフラグ（-<セクション>.<キー>。例: -http.port 8080, -database.max-open-conns 50）
+//
+// 設定ファイルのキーは key タグの名前をセクションごとにまとめたもの（例: http.port）。
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	HTTP           HTTP           `key:"http"`
+	Database       Database       `key:"database"`
+	Health         Health         `key:"health"`
+	Telemetry      Telemetry      `key:"telemetry"`
+	Log            Log            `key:"log"`
+	Robot          Robot          `key:"robot"`
+	OrderCache     OrderCache     `key:"order_cache"`
+	Idempotency    Idempotency    `key:"idempotency"`
+	Recommendation Recommendation `key:"recommendation"`
+	DomainEvents   DomainEvents   `key:"domain_events"`
+	Webhook        Webhook        `key:"webhook"`
+	ImageStore     ImageStore     `key:"image_store"`
+	Thumbnail      Thumbnail      `key:"thumbnail"`
+}
+
+type HTTP struct {
+	Port              string        `key:"port" env:"PORT" default:"8080" help:"port to listen on"`
+	ReadHeaderTimeout time.Duration `key:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" default:"5s"`
+	ReadTimeout       time.Duration `key:"read_timeout" env:"HTTP_READ_TIMEOUT" default:"15s"`
+	WriteTimeout      time.Duration `key:"write_timeout" env:"HTTP_WRITE_TIMEOUT" default:"30s"`
+	IdleTimeout       time.Duration `key:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" default:"120s"`
+	MaxHeaderBytes    int           `key:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES" default:"1048576"`
+	// 停止を始めてから新しい接続を止めるまでの時間
+	// この間は readiness が失敗を返すため、ロードバランサーが振り分けをやめるのを待てる
+	ShutdownDelay time.Duration `key:"shutdown_delay" env:"HTTP_SHUTDOWN_DELAY" default:"2s" help:"time to keep serving with failing readiness before shutdown"`
+	// shutdown_delay と合わせて docker stop の既定の猶予（10s）に収まるようにする
+	ShutdownTimeout time.Duration `key:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" default:"6s" help:"time to wait for in-flight requests on shutdown"`
+	// 注文履歴の書き出しは件数が多いと WriteTimeout を超えるため、別に設定する
+	ExportWriteTimeout time.Duration `key:"export_write_timeout" env:"HTTP_EXPORT_WRITE_TIMEOUT" default:"10m"`
+}
+
+type Database struct {
+	URL             string        `key:"url" env:"DATABASE_URL" default:"user:password@tcp(db:4306)/42Tokyo2508-db" secret:"dsn" help:"MySQL DSN without parameters"`
+	MaxOpenConns    int           `key:"max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"25"`
+	MaxIdleConns    int           `key:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"10"`
+	ConnMaxLifetime time.Duration `key:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" default:"0s" help:"0 keeps connections forever"`
+	PingTimeout     time.Duration `key:"ping_timeout" env:"DB_PING_TIMEOUT" default:"5s"`
+}
+
+type Health struct {
+	// readiness で DB に ping するときのタイムアウト
+	PingTimeout time.Duration `key:"ping_timeout" env:"HEALTH_PING_TIMEOUT" default:"1s"`
+}
+
+type Telemetry struct {
+	// auto の場合はエクスポート先が設定されているときだけ有効にする
+	Enabled        string `key:"enabled" env:"TRACE_ENABLED" default:"auto" help:"auto, true or false"`
+	JaegerEndpoint string `key:"jaeger_endpoint" env:"JAEGER_ENDPOINT"`
+	OTLPEndpoint   string `key:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	Sampler        string `key:"sampler" env:"OTEL_TRACES_SAMPLER" help:"always_on, always_off or empty for ratio-based sampling"`
+	// 設定した場合は sampler より優先する（空の場合は sampler、それも空なら 0.01）
+	SampleRatio string `key:"sample_ratio" env:"TRACE_SAMPLE_RATIO" help:"0 to 1, overrides sampler"`
+	ServiceName string `key:"service_name" env:"SERVICE_NAME" default:"backend"`
+	Environment string `key:"environment" env:"ENV,GO_ENV" default:"local"`
+}
+
+// ログは JSON で標準エラー出力に書き出す
+// debug / info のログはメッセージごとに、1秒あたり sample_initial 件を超えた分を sample_thereafter 件に1件だけ残す
+// （画像の配信など、リクエストごとに出るログで出力が埋まらないようにする）
+type Log struct {
+	Level            string `key:"level" env:"LOG_LEVEL" default:"info" help:"debug, info, warn or error"`
+	SampleInitial    int    `key:"sample_initial" env:"LOG_SAMPLE_INITIAL" default:"100" help:"0 disables sampling"`
+	SampleThereafter int    `key:"sample_thereafter" env:"LOG_SAMPLE_THEREAFTER" default:"100" help:"0 drops everything over sample_initial"`
+}
+
+// トレースを送信するかどうか
+func (t Telemetry) On() bool {
+	switch strings.ToLower(t.Enabled) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return t.JaegerEndpoint != "" || t.OTLPEndpoint != ""
+}
+
+// sample_ratio の値（設定されていない場合は ok=false）
+func (t Telemetry) Ratio() (ratio float64, ok bool) {
+	if t.SampleRatio == "" {
+		return 0, false
+	}
+	ratio, err := strconv.ParseFloat(t.SampleRatio, 64)
+	return ratio, err == nil
+}
+
+// 既定の API キー（本番では必ず変更する）
+const DefaultRobotAPIKey = "test-robot-key"
+
+type Robot struct {
+	APIKey string `key:"api_key" env:"ROBOT_API_KEY" default:"test-robot-key" secret:"true"`
+}
+
+type OrderCache struct {
+	MaxEntries int           `key:"max_entries" env:"ORDER_CACHE_MAX_ENTRIES" default:"10000"`
+	TTL        time.Duration `key:"ttl" env:"ORDER_CACHE_TTL" default:"1s"`
+}
+
+type Idempotency struct {
+	PurgeInterval time.Duration `key:"purge_interval" env:"IDEMPOTENCY_PURGE_INTERVAL" default:"10m"`
+}
+
+type Recommendation struct {
+	RefreshInterval time.Duration `key:"refresh_interval" env:"RECOMMENDATION_REFRESH_INTERVAL" default:"1h"`
+}
+
+// 設定されているものをドメインイベントの配信先に追加する
+type DomainEvents struct {
+	File    string `key:"file" env:"DOMAIN_EVENT_FILE" help:"append domain events to this JSON Lines file"`
+	HTTPURL string `key:"http_url" env:"DOMAIN_EVENT_HTTP_URL" help:"POST domain events to this URL"`
+	// すべての配信先が送信済みのイベントを残す期間
+	Retention     time.Duration `key:"retention" env:"DOMAIN_EVENT_RETENTION" default:"168h"`
+	PurgeInterval time.Duration `key:"purge_interval" env:"DOMAIN_EVENT_PURGE_INTERVAL" default:"10m"`
+}
+
+type Webhook struct {
+	// 送信が済んだ配送と試行ログを残す期間
+	DeliveryRetention time.Duration `key:"delivery_retention" env:"WEBHOOK_DELIVERY_RETENTION" default:"168h"`
+	PurgeInterval     time.Duration `key:"purge_interval" env:"WEBHOOK_PURGE_INTERVAL" default:"10m"`
+}
+
+type ImageStore struct {
+	// 空の場合はストアを使わず /app/images のファイルをそのまま配信する
+	Kind       string `key:"kind" env:"IMAGE_STORE" help:"fs, s3 or empty"`
+	Dir        string `key:"dir" env:"IMAGE_STORE_DIR" default:"/app/image-store"`
+	CacheBytes int64  `key:"cache_bytes" env:"IMAGE_STORE_CACHE_BYTES" default:"0"`
+	S3         S3     `key:"s3"`
+}
+
+type S3 struct {
+	Endpoint  string `key:"endpoint" env:"IMAGE_STORE_S3_ENDPOINT"`
+	Bucket    string `key:"bucket" env:"IMAGE_STORE_S3_BUCKET"`
+	Region    string `key:"region" env:"IMAGE_STORE_S3_REGION"`
+	AccessKey string `key:"access_key" env:"IMAGE_STORE_S3_ACCESS_KEY"`
+	SecretKey string `key:"secret_key" env:"IMAGE_STORE_S3_SECRET_KEY" secret:"true"`
+}
+
+type Thumbnail struct {
+	// 空の場合は一時ディレクトリの下の thumbnails を使う
+	CacheDir string `key:"cache_dir" env:"THUMBNAIL_CACHE_DIR"`
+	// キャッシュの合計サイズの上限（超えた場合は長く使われていないものから削除する）
+	CacheBytes int64 `key:"cache_bytes" env:"THUMBNAIL_CACHE_BYTES" default:"536870912"`
+}
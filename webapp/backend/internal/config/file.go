@@ -0,0 +1,144 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 設定ファイルを読み込み、「セクション.キー」ごとの値を返す
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var values map[string]string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		values, err = parseYAML(data)
+	case ".toml":
+		values, err = parseTOML(data)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported extension %q (want .yaml, .yml or .toml)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func parseYAML(data []byte) (map[string]string, error) {
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	var flatten func(m map[string]any, prefix string) error
+	flatten = func(m map[string]any, prefix string) error {
+		for k, v := range m {
+			key := prefix + k
+			switch v := v.(type) {
+			case map[string]any:
+				if err := flatten(v, key+"."); err != nil {
+					return err
+				}
+			case []any:
+				return fmt.Errorf("%s: lists are not supported", key)
+			case nil:
+				// 値のないキーは既定値のままにする
+			default:
+				values[key] = fmt.Sprint(v)
+			}
+		}
+		return nil
+	}
+	if err := flatten(doc, ""); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// 設定に必要な範囲の TOML を読む
+// [セクション] / [セクション.サブセクション] と「キー = 値」（文字列・数値・真偽値）だけに対応する
+func parseTOML(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	prefix := ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: invalid table header", lineNo)
+			}
+			if rest := strings.TrimSpace(line[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, fmt.Errorf("line %d: unexpected %q after table header", lineNo, rest)
+			}
+			prefix = strings.TrimSpace(line[1:end]) + "."
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		k = strings.TrimSpace(k)
+		val, err := tomlValue(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", lineNo, k, err)
+		}
+		values[prefix+k] = val
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func tomlValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		// 閉じる引用符より後ろはコメントとして扱う
+		end := closingQuote(s)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return strconv.Unquote(s[:end+1])
+	case strings.HasPrefix(s, "'"):
+		end := strings.Index(s[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], nil
+	}
+	if i := strings.Index(s, "#"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	if s == "" {
+		return "", fmt.Errorf("missing value")
+	}
+	// 数値の区切りの _ は取り除く
+	return strings.ReplaceAll(s, "_", ""), nil
+}
+
+// 基本文字列の閉じる引用符の位置（エスケープされたものは除く）
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
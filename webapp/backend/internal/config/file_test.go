@@ -0,0 +1,95 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTOMLValue(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: `"8080"`, want: "8080"},
+		{in: `"a # b" # comment`, want: "a # b"},
+		{in: `"say \"hi\"\t"`, want: "say \"hi\"\t"},
+		{in: `'C:\path' # comment`, want: `C:\path`},
+		{in: `1_000_000`, want: "1000000"},
+		{in: `25 # connections`, want: "25"},
+		{in: `true`, want: "true"},
+		{in: `"unterminated`, wantErr: true},
+		{in: `'unterminated`, wantErr: true},
+		{in: `# only a comment`, wantErr: true},
+		{in: ``, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := tomlValue(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("tomlValue(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	data := `
+# 設定ファイル
+[http]
+port = "8081"
+max_header_bytes = 1_048_576 # 1MiB
+
+[image_store.s3] # サブセクション
+bucket = 'images'
+`
+	got, err := parseTOML([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"http.port":             "8081",
+		"http.max_header_bytes": "1048576",
+		"image_store.s3.bucket": "images",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTOML() = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{
+		"[http",
+		"[[http]]",
+		"[http] port = 1",
+		"port",
+		`port = "8080`,
+	} {
+		if _, err := parseTOML([]byte(bad)); err == nil {
+			t.Errorf("parseTOML(%q): want an error", bad)
+		}
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	data := `
+http:
+  port: 8081
+image_store:
+  s3:
+    bucket: images
+log:
+  level:
+`
+	got, err := parseYAML([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 値のないキーは含めない
+	want := map[string]string{
+		"http.port":             "8081",
+		"image_store.s3.bucket": "images",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYAML() = %v, want %v", got, want)
+	}
+	if _, err := parseYAML([]byte("http:\n  port: [1, 2]\n")); err == nil {
+		t.Error("parseYAML() with a list: want an error")
+	}
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 設定の1項目
+type field struct {
+	key    string // 設定ファイルのキー（例: http.port）
+	envs   []string
+	def    string
+	secret string // "true" または "dsn"（パスワードだけを伏せる）
+	help   string
+	value  reflect.Value
+}
+
+func (f *field) flagName() string {
+	return strings.ReplaceAll(f.key, "_", "-")
+}
+
+// 設定の各項目を定義順に返す
+func fields(cfg *Config) []*field {
+	var out []*field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			key := prefix + sf.Tag.Get("key")
+			if sf.Type.Kind() == reflect.Struct && sf.Type != reflect.TypeOf(time.Duration(0)) {
+				walk(v.Field(i), key+".")
+				continue
+			}
+			var envs []string
+			if e := sf.Tag.Get("env"); e != "" {
+				envs = strings.Split(e, ",")
+			}
+			out = append(out, &field{
+				key:    key,
+				envs:   envs,
+				def:    sf.Tag.Get("default"),
+				secret: sf.Tag.Get("secret"),
+				help:   sf.Tag.Get("help"),
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), "")
+	return out
+}
+
+// 文字列を項目の型に変換して設定する
+func (f *field) set(s string) error {
+	v := f.value
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Int, v.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case v.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// 項目の値を文字列にする（設定ファイルやフラグに書ける形）
+func (f *field) String() string {
+	v := f.value
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return time.Duration(v.Int()).String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// 設定を読み込んで検証する
+// fs に設定ファイルの -config と各項目のフラグを登録し、args を解析する。
+// 呼び出し側で独自のフラグを先に fs に登録しておいてよい
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := &Config{}
+	all := fields(cfg)
+
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file (env CONFIG_FILE)")
+	flagValues := make(map[*field]string)
+	for _, f := range all {
+		f := f
+		usage := f.help
+		if len(f.envs) > 0 {
+			usage = strings.TrimSpace(usage + " (env " + strings.Join(f.envs, ", ") + ")")
+		}
+		fs.Func(f.flagName(), usage, func(s string) error {
+			flagValues[f] = s
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, f := range all {
+		if f.def == "" {
+			continue
+		}
+		if err := f.set(f.def); err != nil {
+			errs = append(errs, fmt.Errorf("default %s=%q: %w", f.key, f.def, err))
+		}
+	}
+
+	if *configFile != "" {
+		values, err := readFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+		byKey := make(map[string]*field, len(all))
+		for _, f := range all {
+			byKey[f.key] = f
+		}
+		for key, s := range values {
+			f, ok := byKey[key]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown key %q", *configFile, key))
+				continue
+			}
+			if err := f.set(s); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s=%q: %w", *configFile, key, s, err))
+			}
+		}
+	}
+
+	for _, f := range all {
+		for _, env := range f.envs {
+			s, ok := os.LookupEnv(env)
+			if !ok || s == "" {
+				continue
+			}
+			if err := f.set(s); err != nil {
+				errs = append(errs, fmt.Errorf("env %s=%q: %w", env, s, err))
+			}
+			break
+		}
+	}
+
+	for _, f := range all {
+		if s, ok := flagValues[f]; ok {
+			if err := f.set(s); err != nil {
+				errs = append(errs, fmt.Errorf("flag -%s=%q: %w", f.flagName(), s, err))
+			}
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if cfg.Thumbnail.CacheDir == "" {
+		cfg.Thumbnail.CacheDir = filepath.Join(os.TempDir(), "thumbnails")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 実行環境の環境変数が結果に影響しないよう、設定に使う環境変数をすべて空にする
+func clearEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	for _, f := range fields(&Config{}) {
+		for _, env := range f.envs {
+			t.Setenv(env, "")
+		}
+	}
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func load(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, args)
+}
+
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+	cfg, err := load(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HTTP.Port != "8080" || cfg.HTTP.ReadTimeout != 15*time.Second || cfg.OrderCache.MaxEntries != 10000 {
+		t.Errorf("defaults = %+v, %+v", cfg.HTTP, cfg.OrderCache)
+	}
+	if cfg.Thumbnail.CacheDir != filepath.Join(os.TempDir(), "thumbnails") {
+		t.Errorf("thumbnail.cache_dir = %q, want the default under the temp dir", cfg.Thumbnail.CacheDir)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeConfig(t, "config.toml", `
+[http]
+port = "8081"
+max_header_bytes = 2_048
+
+[database]
+max_open_conns = 30
+`)
+	t.Setenv("PORT", "8082")
+	t.Setenv("DB_MAX_OPEN_CONNS", "40")
+	// 複数の環境変数がある項目は先に書かれたものを使う
+	t.Setenv("ENV", "production")
+	t.Setenv("GO_ENV", "staging")
+
+	cfg, err := load(t, "-config", path, "-http.port", "8083")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"default", cfg.OrderCache.MaxEntries, 10000},
+		{"file over default", cfg.HTTP.MaxHeaderBytes, 2048},
+		{"env over file", cfg.Database.MaxOpenConns, 40},
+		{"flag over env", cfg.HTTP.Port, "8083"},
+		{"first env", cfg.Telemetry.Environment, "production"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		args    []string
+		want    string
+	}{
+		{name: "unknown key", file: "c.yaml", content: "http:\n  prot: 1\n", want: `unknown key "http.prot"`},
+		{name: "bad file value", file: "c.toml", content: "[http]\nread_timeout = \"soon\"\n", want: "http.read_timeout"},
+		{name: "unsupported extension", file: "c.json", content: "{}", want: "unsupported extension"},
+		{name: "bad env", env: map[string]string{"DB_MAX_OPEN_CONNS": "many"}, want: "env DB_MAX_OPEN_CONNS"},
+		{name: "bad flag", args: []string{"-http.max-header-bytes", "big"}, want: "flag -http.max-header-bytes"},
+		{name: "invalid value", args: []string{"-http.port", "0"}, want: "http.port: must be a port number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeConfig(t, tt.file, tt.content)}, args...)
+			}
+			_, err := load(t, args...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "********"
+
+// 秘密の値を伏せた設定を YAML で書き出す（設定ファイルとしてそのまま使える形）
+func (c *Config) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := make(map[string]*yaml.Node)
+	for _, f := range fields(c) {
+		parent := root
+		parts := strings.Split(f.key, ".")
+		for i := range parts[:len(parts)-1] {
+			path := strings.Join(parts[:i+1], ".")
+			node, ok := sections[path]
+			if !ok {
+				node = &yaml.Node{Kind: yaml.MappingNode}
+				sections[path] = node
+				parent.Content = append(parent.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Value: parts[i]}, node)
+			}
+			parent = node
+		}
+		value := &yaml.Node{Kind: yaml.ScalarNode, Value: f.redactedString()}
+		if f.value.Kind() == reflect.String {
+			// 数値や真偽値に見える文字列も文字列として書く
+			value.Style = yaml.DoubleQuotedStyle
+		}
+		parent.Content = append(parent.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: parts[len(parts)-1]}, value)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func (f *field) redactedString() string {
+	s := f.String()
+	switch {
+	case s == "":
+		return s
+	case f.secret == "dsn":
+		return RedactDSN(s)
+	case f.secret != "":
+		return redacted
+	}
+	return s
+}
+
+// DSN のパスワードを伏せる（ログへの出力用）
+// 解析できない場合は全体を伏せる
+func RedactDSN(dsn string) string {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return redacted
+	}
+	if cfg.Passwd != "" {
+		cfg.Passwd = redacted
+	}
+	return cfg.FormatDSN()
+}
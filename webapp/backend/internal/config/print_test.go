@@ -0,0 +1,64 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"user:password@tcp(db:4306)/app", "user:********@tcp(db:4306)/app"},
+		{"user@tcp(db:4306)/app", "user@tcp(db:4306)/app"},
+		{"not a dsn", redacted},
+	}
+	for _, tt := range tests {
+		if got := RedactDSN(tt.dsn); got != tt.want {
+			t.Errorf("RedactDSN(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DATABASE_URL", "user:password@tcp(db:4306)/app")
+	t.Setenv("ROBOT_API_KEY", "robot-secret")
+	cfg, err := load(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := cfg.Print(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, secret := range []string{"password", "robot-secret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("output contains %q:\n%s", secret, out)
+		}
+	}
+	for _, want := range []string{
+		"http:\n  port: \"8080\"\n",
+		`url: "user:********@tcp(db:4306)/app"`,
+		`api_key: "********"`,
+		"read_timeout: 15s",
+		"image_store:\n",
+		"  s3:\n    endpoint: \"\"\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+
+	// 書き出した設定はそのまま設定ファイルとして読める
+	clearEnv(t)
+	again, err := load(t, "-config", writeConfig(t, "printed.yaml", out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.HTTP != cfg.HTTP || again.Database.URL != "user:********@tcp(db:4306)/app" {
+		t.Errorf("reloaded = %+v, %+v", again.HTTP, again.Database)
+	}
+}
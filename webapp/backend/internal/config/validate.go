@@ -0,0 +1,96 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// 設定の値を検証する
+// 問題のある項目はまとめて返す
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	port, err := strconv.Atoi(c.HTTP.Port)
+	check(err == nil && port >= 1 && port <= 65535, "http.port: must be a port number, got %q", c.HTTP.Port)
+	check(c.HTTP.ReadHeaderTimeout > 0, "http.read_header_timeout: must be positive")
+	check(c.HTTP.ReadTimeout > 0, "http.read_timeout: must be positive")
+	check(c.HTTP.WriteTimeout > 0, "http.write_timeout: must be positive")
+	check(c.HTTP.IdleTimeout > 0, "http.idle_timeout: must be positive")
+	check(c.HTTP.MaxHeaderBytes > 0, "http.max_header_bytes: must be positive")
+	check(c.HTTP.ShutdownDelay >= 0, "http.shutdown_delay: must not be negative")
+	check(c.HTTP.ShutdownTimeout > 0, "http.shutdown_timeout: must be positive")
+	check(c.HTTP.ExportWriteTimeout >= c.HTTP.WriteTimeout, "http.export_write_timeout: must not be shorter than http.write_timeout")
+
+	check(c.Database.URL != "", "database.url: required")
+	check(c.Database.MaxOpenConns > 0, "database.max_open_conns: must be positive")
+	check(c.Database.MaxIdleConns >= 0 && c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns: must be between 0 and database.max_open_conns")
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime: must not be negative")
+	check(c.Database.PingTimeout > 0, "database.ping_timeout: must be positive")
+	check(c.Health.PingTimeout > 0, "health.ping_timeout: must be positive")
+
+	switch strings.ToLower(c.Telemetry.Enabled) {
+	case "auto", "true", "false":
+	default:
+		check(false, "telemetry.enabled: must be auto, true or false, got %q", c.Telemetry.Enabled)
+	}
+	switch strings.ToLower(c.Telemetry.Sampler) {
+	case "", "always_on", "always_off":
+	default:
+		check(false, "telemetry.sampler: must be always_on, always_off or empty, got %q", c.Telemetry.Sampler)
+	}
+	if c.Telemetry.SampleRatio != "" {
+		ratio, ok := c.Telemetry.Ratio()
+		check(ok && ratio >= 0 && ratio <= 1, "telemetry.sample_ratio: must be between 0 and 1, got %q", c.Telemetry.SampleRatio)
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "log.level: must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	check(c.Log.SampleInitial >= 0, "log.sample_initial: must not be negative")
+	check(c.Log.SampleThereafter >= 0, "log.sample_thereafter: must not be negative")
+
+	check(c.Robot.APIKey != "", "robot.api_key: required")
+
+	check(c.OrderCache.MaxEntries > 0, "order_cache.max_entries: must be positive")
+	check(c.OrderCache.TTL > 0, "order_cache.ttl: must be positive")
+	check(c.Idempotency.PurgeInterval > 0, "idempotency.purge_interval: must be positive")
+	check(c.Recommendation.RefreshInterval > 0, "recommendation.refresh_interval: must be positive")
+	if c.DomainEvents.HTTPURL != "" {
+		check(validHTTPURL(c.DomainEvents.HTTPURL), "domain_events.http_url: must be an http(s) URL")
+	}
+	check(c.DomainEvents.Retention > 0, "domain_events.retention: must be positive")
+	check(c.DomainEvents.PurgeInterval > 0, "domain_events.purge_interval: must be positive")
+	check(c.Webhook.DeliveryRetention > 0, "webhook.delivery_retention: must be positive")
+	check(c.Webhook.PurgeInterval > 0, "webhook.purge_interval: must be positive")
+
+	switch c.ImageStore.Kind {
+	case "":
+	case "fs":
+		check(c.ImageStore.Dir != "", "image_store.dir: required when image_store.kind is fs")
+	case "s3":
+		check(validHTTPURL(c.ImageStore.S3.Endpoint), "image_store.s3.endpoint: must be an http(s) URL")
+		check(c.ImageStore.S3.Bucket != "", "image_store.s3.bucket: required when image_store.kind is s3")
+	default:
+		check(false, "image_store.kind: must be fs, s3 or empty, got %q", c.ImageStore.Kind)
+	}
+	check(c.ImageStore.CacheBytes >= 0, "image_store.cache_bytes: must not be negative")
+	check(c.Thumbnail.CacheBytes > 0, "thumbnail.cache_bytes: must be positive")
+
+	return errors.Join(errs...)
+}
+
+func validHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
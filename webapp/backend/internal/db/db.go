@@ -1,45 +1,42 @@
 package db
 
 import (
+	"backend/internal/config"
 	"backend/internal/telemetry"
 	"context"
 	"fmt"
-	"log"
-	"os"
-	"time"
+	"log/slog"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 )
 
-func InitDBConnection() (*sqlx.DB, error) {
-	dbUrl := os.Getenv("DATABASE_URL")
-	if dbUrl == "" {
-		dbUrl = "user:password@tcp(db:4306)/42Tokyo2508-db"
-	}
-	dsn := fmt.Sprintf("%s?charset=utf8mb4&parseTime=True&loc=Local", dbUrl)
-	log.Printf(dsn)
+// traceSQL が true の場合はクエリをトレースする
+func InitDBConnection(cfg config.Database, traceSQL bool) (*sqlx.DB, error) {
+	dsn := fmt.Sprintf("%s?charset=utf8mb4&parseTime=True&loc=Local", cfg.URL)
+	// DSN にはパスワードが含まれるため、伏せてから出力する
+	slog.Info("Connecting to database", "dsn", config.RedactDSN(dsn))
 
-	driverName := telemetry.WrapSQLDriver("mysql")
+	driverName := telemetry.WrapSQLDriver("mysql", traceSQL)
 	dbConn, err := sqlx.Open(driverName, dsn)
 	if err != nil {
-		log.Printf("Failed to open database connection: %v", err)
+		slog.Error("Failed to open database connection", "error", err)
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.PingTimeout)
 	defer cancel()
 	err = dbConn.PingContext(ctx)
 	if err != nil {
 		dbConn.Close()
-		log.Printf("Failed to connect to database: %v", err)
+		slog.Error("Failed to connect to database", "error", err)
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-	log.Println("Successfully connected to MySQL!")
+	slog.Info("Successfully connected to MySQL")
 
-	dbConn.SetMaxOpenConns(25)
-	dbConn.SetMaxIdleConns(10)
-	dbConn.SetConnMaxLifetime(0)
+	dbConn.SetMaxOpenConns(cfg.MaxOpenConns)
+	dbConn.SetMaxIdleConns(cfg.MaxIdleConns)
+	dbConn.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
 	return dbConn, nil
 }
@@ -0,0 +1,153 @@
+// DB を使うテストの共通処理
+//
+// 環境変数 TEST_DATABASE_URL の MySQL にテストごとのデータベースを作り、
+// mysql/init/init.sql と mysql/migration の SQL を番号順に実行してから返す。
+// TEST_DATABASE_URL が設定されていない場合はテストをスキップする。
+//
+//	TEST_DATABASE_URL='root:mysql@tcp(127.0.0.1:3306)/' go test ./...
+package dbtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// init.sql の USE 文（作成したデータベースを使うため取り除く）
+var useStmt = regexp.MustCompile("(?m)^USE .*;$")
+
+// スキーマを作成したデータベースに接続して返す
+// データベースはテストの終了時に削除する
+func Open(t testing.TB) *sqlx.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	cfg, err := mysql.ParseDSN(url)
+	if err != nil {
+		t.Fatalf("invalid TEST_DATABASE_URL: %v", err)
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.Local
+	cfg.DBName = ""
+
+	admin, err := sqlx.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	name := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+
+	cfg.DBName = name
+	db, err := sqlx.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if admin, err := sqlx.Open("mysql", url); err == nil {
+			admin.Exec("DROP DATABASE " + name)
+			admin.Close()
+		}
+	})
+
+	for _, path := range schemaFiles(t) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := execAll(db, useStmt.ReplaceAllString(string(b), "")); err != nil {
+			t.Fatalf("failed to apply %s: %v", filepath.Base(path), err)
+		}
+	}
+	return db
+}
+
+// init.sql と、番号順に並べたマイグレーションのパス
+func schemaFiles(t testing.TB) []string {
+	_, file, _, _ := runtime.Caller(0)
+	mysqlDir := filepath.Join(filepath.Dir(file), "..", "..", "..", "mysql")
+
+	migrations, err := filepath.Glob(filepath.Join(mysqlDir, "migration", "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	number := func(path string) int {
+		n, _ := strconv.Atoi(strings.SplitN(filepath.Base(path), "_", 2)[0])
+		return n
+	}
+	sort.Slice(migrations, func(i, j int) bool { return number(migrations[i]) < number(migrations[j]) })
+	return append([]string{filepath.Join(mysqlDir, "init", "init.sql")}, migrations...)
+}
+
+func stripComments(s string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(s, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// SQL ファイルの文を1つずつ実行する（文字列中に ; を含む文はない前提）
+func execAll(db *sqlx.DB, stmts string) error {
+	for _, s := range strings.Split(stripComments(stmts), ";") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		if _, err := db.Exec(s); err != nil {
+			return fmt.Errorf("%w\n%s", err, s)
+		}
+	}
+	return nil
+}
+
+// ユーザーを作成し、ユーザーIDを返す
+func CreateUser(t testing.TB, db *sqlx.DB, name string) int {
+	t.Helper()
+	res, err := db.Exec("INSERT INTO users (password_hash, user_name) VALUES ('', ?)", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return int(id)
+}
+
+// 商品を作成し、商品IDを返す
+func CreateProduct(t testing.TB, db *sqlx.DB, name string, weight int) int {
+	t.Helper()
+	res, err := db.Exec("INSERT INTO products (name, value, weight, image, description) VALUES (?, 100, ?, '', '')", name, weight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return int(id)
+}
+
+// 注文を直接挿入し、注文IDを返す（order_id_sequence は使わない）
+func CreateOrder(t testing.TB, db *sqlx.DB, userID, productID int, status string, createdAt time.Time) int64 {
+	t.Helper()
+	res, err := db.Exec("INSERT INTO orders (user_id, product_id, shipped_status, created_at) VALUES (?, ?, ?, ?)",
+		userID, productID, status, createdAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return id
+}
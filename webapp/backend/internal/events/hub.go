@@ -0,0 +1,165 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// 再接続時の再送用に保持する直近のイベント数
+	defaultHistorySize = 10000
+	// 接続ごとの送信待ちバッファ
+	defaultSubscriberBuffer = 64
+)
+
+// 注文ステータスの変更通知
+type OrderStatusEvent struct {
+	ID        uint64    `json:"id"`
+	UserID    int       `json:"-"`
+	OrderID   int64     `json:"order_id"`
+	OldStatus string    `json:"old_status"`
+	NewStatus string    `json:"new_status"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// ユーザーごとに注文ステータスの変更を配信するプロセス内の Pub/Sub
+// 直近のイベントをリングバッファに保持し、Last-Event-ID による再送に使う
+//
+// イベントIDは起動時刻（マイクロ秒）から採番するため、再起動後のIDは前のプロセスのIDより大きくなる
+// 前のプロセスのIDで再接続された場合は、保持しているイベントをすべて再送する
+type Hub struct {
+	mu sync.Mutex
+	// このプロセスで最初に採番するIDの1つ前
+	epoch       uint64
+	nextID      uint64
+	history     []OrderStatusEvent
+	historyHead int
+	historyLen  int
+	subscribers map[int]map[*Subscription]struct{}
+	bufferSize  int
+}
+
+func NewHub() *Hub {
+	return newHub(uint64(time.Now().UnixMicro()))
+}
+
+func newHub(epoch uint64) *Hub {
+	return &Hub{
+		epoch:       epoch,
+		nextID:      epoch,
+		history:     make([]OrderStatusEvent, defaultHistorySize),
+		subscribers: make(map[int]map[*Subscription]struct{}),
+		bufferSize:  defaultSubscriberBuffer,
+	}
+}
+
+// 購読中の接続
+// バッファが溢れた購読は Dropped が閉じられ、クライアントは Last-Event-ID で再接続する
+type Subscription struct {
+	C       chan OrderStatusEvent
+	Dropped chan struct{}
+	userID  int
+	hub     *Hub
+	once    sync.Once
+}
+
+// イベントを発行する
+// ID は発行順に採番される
+func (h *Hub) Publish(events ...OrderStatusEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, ev := range events {
+		h.nextID++
+		ev.ID = h.nextID
+		h.appendHistory(ev)
+
+		for sub := range h.subscribers[ev.UserID] {
+			select {
+			case sub.C <- ev:
+			default:
+				// 送信が追いつかない接続は切断して再接続させる
+				h.dropLocked(sub)
+			}
+		}
+	}
+}
+
+// ユーザーのイベントを購読する
+// lastEventID より後に発行され、まだ保持しているイベントを併せて返す
+// lastEventID が再起動前のIDの場合は、保持しているイベントをすべて返す
+func (h *Hub) Subscribe(userID int, lastEventID uint64) (*Subscription, []OrderStatusEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &Subscription{
+		C:       make(chan OrderStatusEvent, h.bufferSize),
+		Dropped: make(chan struct{}),
+		userID:  userID,
+		hub:     h,
+	}
+	subs, ok := h.subscribers[userID]
+	if !ok {
+		subs = make(map[*Subscription]struct{})
+		h.subscribers[userID] = subs
+	}
+	subs[sub] = struct{}{}
+
+	var missed []OrderStatusEvent
+	if lastEventID > 0 {
+		// このプロセスが採番したIDでなければ、再起動前に受け取ったIDとみなす
+		since := lastEventID
+		if since <= h.epoch || since > h.nextID {
+			since = 0
+		}
+		for i := 0; i < h.historyLen; i++ {
+			ev := h.history[(h.historyHead+i)%len(h.history)]
+			if ev.ID > since && ev.UserID == userID {
+				missed = append(missed, ev)
+			}
+		}
+	}
+	return sub, missed
+}
+
+// 購読をやめる
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.removeLocked(s)
+}
+
+// 購読中の接続数
+func (h *Hub) SubscriberCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for _, subs := range h.subscribers {
+		n += len(subs)
+	}
+	return n
+}
+
+func (h *Hub) appendHistory(ev OrderStatusEvent) {
+	if h.historyLen < len(h.history) {
+		h.history[(h.historyHead+h.historyLen)%len(h.history)] = ev
+		h.historyLen++
+		return
+	}
+	h.history[h.historyHead] = ev
+	h.historyHead = (h.historyHead + 1) % len(h.history)
+}
+
+func (h *Hub) dropLocked(sub *Subscription) {
+	h.removeLocked(sub)
+	sub.once.Do(func() { close(sub.Dropped) })
+}
+
+func (h *Hub) removeLocked(sub *Subscription) {
+	if subs, ok := h.subscribers[sub.userID]; ok {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(h.subscribers, sub.userID)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package events
+
+import "testing"
+
+func TestSubscribeReplaysAfterRestart(t *testing.T) {
+	before := newHub(1000)
+	before.Publish(OrderStatusEvent{UserID: 1, OrderID: 1}, OrderStatusEvent{UserID: 1, OrderID: 2})
+	_, missed := before.Subscribe(1, 1001)
+	if len(missed) != 1 || missed[0].ID != 1002 {
+		t.Fatalf("missed = %+v, want only event 1002", missed)
+	}
+
+	// 再起動後のプロセス。クライアントは前のプロセスのIDで再接続する
+	for _, lastEventID := range []uint64{1002, 1 << 62} {
+		after := newHub(2000)
+		after.Publish(OrderStatusEvent{UserID: 1, OrderID: 3}, OrderStatusEvent{UserID: 2, OrderID: 4})
+		_, missed := after.Subscribe(1, lastEventID)
+		if len(missed) != 1 || missed[0].OrderID != 3 {
+			t.Errorf("Last-Event-ID %d: missed = %+v, want the kept event for order 3", lastEventID, missed)
+		}
+	}
+}
+
+func TestSubscribeWithoutLastEventID(t *testing.T) {
+	h := newHub(0)
+	h.Publish(OrderStatusEvent{UserID: 1, OrderID: 1})
+	if _, missed := h.Subscribe(1, 0); len(missed) != 0 {
+		t.Fatalf("missed = %+v, want none for a new connection", missed)
+	}
+}
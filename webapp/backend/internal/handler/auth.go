@@ -2,10 +2,10 @@ package handler
 
 import (
 	"encoding/json"
-	"errors"
-	"log"
 	"net/http"
 
+	"backend/internal/apierror"
+	"backend/internal/logging"
 	"backend/internal/model"
 	"backend/internal/service"
 )
@@ -20,20 +20,19 @@ func NewAuthHandler(authSvc *service.AuthService) *AuthHandler {
 
 // ログイン時にセッションを発行し、Cookieにセットする
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
-	log.Println("-> Received request for /api/login")
+	logging.FromContext(r.Context()).Debug("Received login request")
 
 	var req model.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.WriteError(w, r, errInvalidBody)
 		return
 	}
 
 	sessionID, expiresAt, err := h.AuthSvc.Login(r.Context(), req.UserName, req.Password)
 	if err != nil {
-		if errors.Is(err, service.ErrUserNotFound) || errors.Is(err, service.ErrInvalidPassword) {
-			http.Error(w, "Unauthorized: Invalid credentials", http.StatusUnauthorized)
-		} else {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		if !writeServiceError(w, r, err) {
+			logging.FromContext(r.Context()).Error("Failed to log in", "error", err)
+			writeInternalError(w, r, "Internal server error")
 		}
 		return
 	}
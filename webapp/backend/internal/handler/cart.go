@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/logging"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type CartHandler struct {
+	CartSvc *service.CartService
+}
+
+func NewCartHandler(svc *service.CartService) *CartHandler {
+	return &CartHandler{CartSvc: svc}
+}
+
+// カートの中身を取得
+func (h *CartHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.WriteError(w, r, errNoUserInContext)
+		return
+	}
+
+	cart, err := h.CartSvc.GetCart(r.Context(), userID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to fetch cart", "user_id", userID, "error", err)
+		writeInternalError(w, r, "Failed to fetch cart")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cart)
+}
+
+// カートに商品を追加
+func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.WriteError(w, r, errNoUserInContext)
+		return
+	}
+
+	var req model.RequestItem
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.WriteError(w, r, errInvalidBody)
+		return
+	}
+
+	if err := h.CartSvc.AddItem(r.Context(), userID, req); err != nil {
+		h.writeError(w, r, userID, err)
+		return
+	}
+	h.Get(w, r)
+}
+
+// カート内の商品の数量を変更
+func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.WriteError(w, r, errNoUserInContext)
+		return
+	}
+
+	productID, err := strconv.Atoi(chi.URLParam(r, "productID"))
+	if err != nil {
+		writeBadRequest(w, r, "Invalid product ID")
+		return
+	}
+	var req model.UpdateCartItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.WriteError(w, r, errInvalidBody)
+		return
+	}
+
+	item := model.RequestItem{ProductID: productID, Quantity: req.Quantity}
+	if err := h.CartSvc.UpdateQuantity(r.Context(), userID, item); err != nil {
+		h.writeError(w, r, userID, err)
+		return
+	}
+	h.Get(w, r)
+}
+
+// カートから商品を削除
+func (h *CartHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.WriteError(w, r, errNoUserInContext)
+		return
+	}
+
+	productID, err := strconv.Atoi(chi.URLParam(r, "productID"))
+	if err != nil {
+		writeBadRequest(w, r, "Invalid product ID")
+		return
+	}
+
+	if err := h.CartSvc.RemoveItem(r.Context(), userID, productID); err != nil {
+		h.writeError(w, r, userID, err)
+		return
+	}
+	h.Get(w, r)
+}
+
+// カートの中身を注文する
+func (h *CartHandler) Checkout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.WriteError(w, r, errNoUserInContext)
+		return
+	}
+
+	result, err := h.CartSvc.Checkout(r.Context(), userID)
+	if err != nil {
+		h.writeError(w, r, userID, err)
+		return
+	}
+
+	writeCreateOrdersResponse(w, result)
+}
+
+func (h *CartHandler) writeError(w http.ResponseWriter, r *http.Request, userID int, err error) {
+	if writeServiceError(w, r, err) {
+		return
+	}
+	logging.FromContext(r.Context()).Error("Failed to update cart", "user_id", userID, "error", err)
+	writeInternalError(w, r, "Failed to process cart request")
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/imagestore"
+	"backend/internal/service"
+	"errors"
+	"net/http"
+)
+
+var (
+	errInvalidBody = apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+	// 認証のミドルウェアを通っていれば発生しない
+	errNoUserInContext = apierror.New(http.StatusInternalServerError, apierror.CodeInternal, "User not found in context")
+)
+
+// サービスのエラーとレスポンスの対応
+var serviceErrors = []struct {
+	err error
+	res *apierror.Error
+}{
+	{service.ErrProductNotFound, apierror.New(http.StatusNotFound, apierror.CodeProductNotFound, "Product not found")},
+	{service.ErrOrderNotFound, apierror.New(http.StatusNotFound, apierror.CodeOrderNotFound, "Order not found")},
+	{service.ErrOrderGroupNotFound, apierror.New(http.StatusNotFound, apierror.CodeOrderGroupNotFound, "Order group not found")},
+	{service.ErrOrderNotCancellable, apierror.New(http.StatusConflict, apierror.CodeOrderNotCancellable, "Order can no longer be cancelled")},
+	{service.ErrOrderCancelled, apierror.New(http.StatusConflict, apierror.CodeOrderCancelled, "Order has been cancelled")},
+	{service.ErrDeliveryPlanConflict, apierror.New(http.StatusConflict, apierror.CodeDeliveryPlanConflict, "Orders changed while creating the delivery plan, retry the request")},
+	{service.ErrCartItemNotFound, apierror.New(http.StatusNotFound, apierror.CodeCartItemNotFound, "Cart item not found")},
+	{service.ErrCartEmpty, apierror.New(http.StatusBadRequest, apierror.CodeCartEmpty, "Cart is empty")},
+	{service.ErrWebhookEndpointNotFound, apierror.New(http.StatusNotFound, apierror.CodeWebhookEndpointNotFound, "Webhook endpoint not found")},
+	{service.ErrIdempotencyKeyMismatch, apierror.New(http.StatusUnprocessableEntity, apierror.CodeIdempotencyKeyMismatch, "Idempotency-Key was already used with a different request")},
+	{service.ErrIdempotencyKeyInUse, apierror.New(http.StatusConflict, apierror.CodeIdempotencyKeyInUse, "A request with the same Idempotency-Key is in progress")},
+	// ユーザーが存在するかどうかは区別しない
+	{service.ErrUserNotFound, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid credentials")},
+	{service.ErrInvalidPassword, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid credentials")},
+	{imagestore.ErrNotFound, apierror.New(http.StatusNotFound, apierror.CodeImageNotFound, "Image not found")},
+}
+
+// サービスのエラーに対応するレスポンスを書き込む
+// 対応するものがなければ何も書き込まずに false を返す（呼び出し側でログを出して 500 を返す）
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) bool {
+	var verr *service.ValidationError
+	if errors.As(err, &verr) {
+		writeValidationError(w, r, verr)
+		return true
+	}
+	for _, se := range serviceErrors {
+		if errors.Is(err, se.err) {
+			apierror.WriteError(w, r, se.res)
+			return true
+		}
+	}
+	return false
+}
+
+// バリデーションエラーを項目ごとのエラー付きで返す
+func writeValidationError(w http.ResponseWriter, r *http.Request, verr *service.ValidationError) {
+	apierror.WriteError(w, r, apierror.New(http.StatusBadRequest, apierror.CodeValidationFailed, "Request validation failed").WithDetails(verr.Errors))
+}
+
+func writeInternalError(w http.ResponseWriter, r *http.Request, message string) {
+	apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, message)
+}
+
+func writeBadRequest(w http.ResponseWriter, r *http.Request, message string) {
+	apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, message)
+}
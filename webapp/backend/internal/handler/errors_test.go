@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/service"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteServiceError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{service.ErrOrderNotFound, http.StatusNotFound, apierror.CodeOrderNotFound},
+		// 再試行しても計画を作れなかった場合は、再試行できるよう 409 で返す
+		{fmt.Errorf("timeout: %w", service.ErrDeliveryPlanConflict), http.StatusConflict, apierror.CodeDeliveryPlanConflict},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		if !writeServiceError(rec, httptest.NewRequest(http.MethodGet, "/", nil), tt.err) {
+			t.Fatalf("%v: not handled", tt.err)
+		}
+		var body struct {
+			Code string `json:"code"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != tt.status || body.Code != tt.code {
+			t.Errorf("%v: got %d %s, want %d %s", tt.err, rec.Code, body.Code, tt.status, tt.code)
+		}
+	}
+
+	if writeServiceError(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), fmt.Errorf("db down")) {
+		t.Error("unknown errors should be left to the caller")
+	}
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"backend/internal/service"
+	"encoding/json"
+	"net/http"
+)
+
+type HealthHandler struct {
+	HealthSvc *service.HealthService
+	// 停止を始めたときに閉じられる
+	draining <-chan struct{}
+}
+
+func NewHealthHandler(svc *service.HealthService, draining <-chan struct{}) *HealthHandler {
+	return &HealthHandler{HealthSvc: svc, draining: draining}
+}
+
+// プロセスが応答できるかだけを返す（依存先は確認しない）
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, map[string]string{"status": service.HealthOK})
+}
+
+// リクエストを受け付けられるかを返す
+// 停止中、または依存先に問題がある場合は 503 を返し、ロードバランサーに振り分けをやめさせる
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	select {
+	case <-h.draining:
+		writeHealth(w, http.StatusServiceUnavailable, map[string]string{"status": "shutting_down"})
+		return
+	default:
+	}
+
+	report := h.HealthSvc.Check(r.Context())
+	status := http.StatusOK
+	if report.Status != service.HealthOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealth(w, status, report)
+}
+
+func writeHealth(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/imagestore"
+	"backend/internal/logging"
+	"backend/internal/thumbnail"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// 画像ストアを使わない場合に画像を配信するディレクトリ
+const defaultImageDir = "/app/images"
+
+// 縮小画像の幅・高さの上限
+const maxThumbnailSize = 2048
+
+// 配信する元の画像
+type imageSource struct {
+	content     io.ReadSeekCloser
+	hash        string // 内容の SHA-256 の先頭16バイト（16進表記）
+	contentType string
+	name        string
+	modTime     time.Time
+}
+
+// 画像ストア、または /app/images から画像を開く
+// ETag は内容のハッシュから作るため、どちらから配信しても同じ値になる
+func (h *ProductHandler) openImage(r *http.Request, imagePath string) (*imageSource, error) {
+	if h.images != nil {
+		obj, err := h.images.Open(r.Context(), imagePath)
+		if err != nil {
+			return nil, err
+		}
+		return &imageSource{
+			content:     obj.Content,
+			hash:        obj.Hash[:32],
+			contentType: obj.ContentType,
+			name:        filepath.Base(imagePath),
+			modTime:     obj.CreatedAt,
+		}, nil
+	}
+
+	// 画像ディレクトリの外（シンボリックリンクの先を含む）は開かない
+	f, err := openInRoot(h.imageDir, imagePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, imagestore.ErrNotFound
+		}
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, imagestore.ErrNotFound
+	}
+	hash, err := h.imageETags.get(f.Name(), info, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &imageSource{
+		content:     f,
+		hash:        hash,
+		contentType: imagestore.ContentTypeByExt(filepath.Ext(imagePath)),
+		name:        info.Name(),
+		modTime:     info.ModTime(),
+	}, nil
+}
+
+// クエリパラメータ w / h を取得（指定がない場合は 0）
+func parseThumbnailSize(r *http.Request) (int, int, error) {
+	parse := func(name string) (int, error) {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxThumbnailSize {
+			return 0, fmt.Errorf("Query parameter '%s' must be an integer between 1 and %d", name, maxThumbnailSize)
+		}
+		return n, nil
+	}
+	width, err := parse("w")
+	if err != nil {
+		return 0, 0, err
+	}
+	height, err := parse("h")
+	if err != nil {
+		return 0, 0, err
+	}
+	return width, height, nil
+}
+
+// 縮小画像を配信する
+// 縮小できない画像の場合は何も書き込まずに false を返す
+func (h *ProductHandler) serveThumbnail(w http.ResponseWriter, r *http.Request, src *imageSource, width, height int) bool {
+	// Accept によって返す形式が変わるため、キャッシュに伝える
+	w.Header().Add("Vary", "Accept")
+
+	format, ok := thumbnail.Negotiate(r.Header.Get("Accept"), src.contentType)
+	if !ok {
+		apierror.Write(w, r, http.StatusNotAcceptable, apierror.CodeNotAcceptable, "No acceptable image format")
+		return true
+	}
+
+	key := thumbnail.Key{Hash: src.hash, Width: width, Height: height, Format: format}
+	f, err := h.thumbnails.Open(r.Context(), key, src.content)
+	if err != nil {
+		if errors.Is(err, thumbnail.ErrUnsupported) {
+			logging.FromContext(r.Context()).Info("縮小できない画像です", "image", src.name, "error", err)
+			return false
+		}
+		logging.FromContext(r.Context()).Error("縮小画像の生成に失敗", "image", src.name, "error", err)
+		writeInternalError(w, r, "Failed to load image")
+		return true
+	}
+
+	defer f.Close()
+
+	w.Header().Set("Content-Type", format)
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-%dx%d-%s"`, src.hash, width, height, thumbnail.Ext(format)))
+	w.Header().Set("Cache-Control", imageCacheControl)
+	http.ServeContent(w, r, src.name, src.modTime, f)
+	return true
+}
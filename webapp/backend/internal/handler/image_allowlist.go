@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// 配信してよい画像のパス（products.image が参照しているもの）の集合
+// 商品の一覧はサーバーの起動時に読み込む
+type imageAllowlist struct {
+	paths atomic.Pointer[map[string]struct{}]
+}
+
+// 読み込む前はどのパスも許可しない
+func (a *imageAllowlist) contains(path string) bool {
+	paths := a.paths.Load()
+	if paths == nil {
+		return false
+	}
+	_, ok := (*paths)[path]
+	return ok
+}
+
+func (a *imageAllowlist) replace(list []string) int {
+	paths := make(map[string]struct{}, len(list))
+	for _, p := range list {
+		if key, ok := imageKey(p); ok {
+			paths[key] = struct{}{}
+		}
+	}
+	a.paths.Store(&paths)
+	return len(paths)
+}
+
+// 比較に使う形にパスを揃える
+// 画像ディレクトリの外を指すパス（絶対パスや .. を含むもの）は ok=false
+func imageKey(path string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+	path = filepath.Clean(path)
+	if !filepath.IsLocal(path) {
+		return "", false
+	}
+	return filepath.ToSlash(path), true
+}
+
+// 商品が参照している画像のパスを読み込み、配信してよい画像を更新する
+func (h *ProductHandler) LoadImageAllowlist(ctx context.Context) (int, error) {
+	paths, err := h.ProductSvc.ListImagePaths(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load image paths: %w", err)
+	}
+	return h.imageAllowlist.replace(paths), nil
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// 保持するハッシュの最大数（超えた場合は作り直す）
+const maxImageETags = 10000
+
+// 画像ファイルの内容から計算したハッシュ（ETag に使う）のキャッシュ
+// ファイルの更新日時とサイズが変わらない間は計算し直さない
+type imageETagCache struct {
+	mu      sync.Mutex
+	entries map[string]imageETag
+}
+
+type imageETag struct {
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+func newImageETagCache() *imageETagCache {
+	return &imageETagCache{entries: make(map[string]imageETag)}
+}
+
+// ファイルの内容の SHA-256 の先頭16バイトを16進表記で返す
+// 画像ストアのハッシュの先頭32文字と同じ値になる。計算した場合は f の読み取り位置を先頭に戻す
+func (c *imageETagCache) get(path string, info os.FileInfo, f io.ReadSeeker) (string, error) {
+	c.mu.Lock()
+	e, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && e.modTime.Equal(info.ModTime()) && e.size == info.Size() {
+		return e.hash, nil
+	}
+
+	// 全体をメモリに載せずに読みながらハッシュを計算する
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil)[:16])
+
+	c.mu.Lock()
+	if len(c.entries) >= maxImageETags {
+		c.entries = make(map[string]imageETag)
+	}
+	c.entries[path] = imageETag{modTime: info.ModTime(), size: info.Size(), hash: sum}
+	c.mu.Unlock()
+	return sum, nil
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func FuzzImageKey(f *testing.F) {
+	for _, p := range []string{"a.png", "dir/a.png", "./a.png", "dir/../a.png", "../a.png", "/etc/passwd", "a//b.png", `..\a.png`, ""} {
+		f.Add(p)
+	}
+	f.Fuzz(func(t *testing.T, path string) {
+		key, ok := imageKey(path)
+		if !ok {
+			return
+		}
+		// 画像ディレクトリの中を指し、揃えた形はそれ以上変わらない
+		if !filepath.IsLocal(filepath.FromSlash(key)) {
+			t.Fatalf("imageKey(%q) = %q, not a local path", path, key)
+		}
+		if again, ok := imageKey(key); !ok || again != key {
+			t.Fatalf("imageKey(%q) = %q, %v, want %q", key, again, ok, key)
+		}
+	})
+}
+
+func FuzzOpenInRoot(f *testing.F) {
+	base := f.TempDir()
+	dir := filepath.Join(base, "images")
+	for _, d := range []string{dir, filepath.Join(dir, "sub")} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			f.Fatal(err)
+		}
+	}
+	const secret = "secret"
+	files := map[string]string{
+		filepath.Join(base, "secret.txt"):  secret,
+		filepath.Join(dir, "a.png"):        "a",
+		filepath.Join(dir, "sub", "b.png"): "b",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			f.Fatal(err)
+		}
+	}
+	// 画像ディレクトリの外と中を指すシンボリックリンク
+	links := map[string]string{
+		filepath.Join(dir, "out.png"):       filepath.Join(base, "secret.txt"),
+		filepath.Join(dir, "sub", "up.png"): "../../secret.txt",
+		filepath.Join(dir, "in.png"):        "sub/b.png",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			f.Skip("symlinks are not supported:", err)
+		}
+	}
+
+	for _, name := range []string{"a.png", "sub/b.png", "in.png", "out.png", "sub/up.png", "../secret.txt", "sub/../../secret.txt", "/etc/passwd", ""} {
+		f.Add(name)
+	}
+	f.Fuzz(func(t *testing.T, name string) {
+		file, err := openInRoot(dir, name)
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		b, _ := io.ReadAll(file)
+		if strings.Contains(string(b), secret) {
+			t.Fatalf("openInRoot(%q) opened a file outside the image directory", name)
+		}
+	})
+}
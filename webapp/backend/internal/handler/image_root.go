@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// dir の中のファイル name を開く
+// シンボリックリンクは dir の中を指す場合だけたどり、dir の外は開けない
+func openInRoot(dir, name string) (*os.File, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	f, err := root.Open(name)
+	if err != nil {
+		// dir の外を指すパスは OS のエラーにならないため、存在しないものとして扱う
+		var errno syscall.Errno
+		if !errors.As(err, &errno) {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		return nil, err
+	}
+	return f, nil
+}
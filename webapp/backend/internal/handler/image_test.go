@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var testImage = []byte("\x89PNG\r\n\x1a\n0123456789abcdef")
+
+// testImage だけを配信するハンドラ
+func newTestImageHandler(t *testing.T) *ProductHandler {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.png")
+	if err := os.WriteFile(path, testImage, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	h := &ProductHandler{imageETags: newImageETagCache(), imageDir: dir}
+	h.imageAllowlist.replace([]string{"a.png"})
+	return h
+}
+
+func getImage(h *ProductHandler, header http.Header) *http.Response {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/image?path=a.png", nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.GetImage(rec, req)
+	return rec.Result()
+}
+
+func TestGetImage(t *testing.T) {
+	h := newTestImageHandler(t)
+	resp := getImage(h, nil)
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if string(body) != string(testImage) {
+		t.Errorf("body = %q, want the image", body)
+	}
+	if got := resp.Header.Get("Cache-Control"); got != imageCacheControl {
+		t.Errorf("Cache-Control = %q, want %q", got, imageCacheControl)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "image/png" {
+		t.Errorf("Content-Type = %q, want image/png", got)
+	}
+	if resp.Header.Get("ETag") == "" || resp.Header.Get("Last-Modified") == "" {
+		t.Errorf("ETag = %q, Last-Modified = %q, want both set", resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"))
+	}
+}
+
+func TestGetImageNotModified(t *testing.T) {
+	h := newTestImageHandler(t)
+	first := getImage(h, nil)
+	etag, lastModified := first.Header.Get("ETag"), first.Header.Get("Last-Modified")
+
+	tests := []struct {
+		name   string
+		header http.Header
+	}{
+		{"If-None-Match", http.Header{"If-None-Match": {etag}}},
+		{"If-Modified-Since", http.Header{"If-Modified-Since": {lastModified}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := getImage(h, tt.header)
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusNotModified {
+				t.Fatalf("status = %d, want 304", resp.StatusCode)
+			}
+			if len(body) != 0 {
+				t.Errorf("body = %q, want empty", body)
+			}
+			if got := resp.Header.Get("ETag"); got != etag {
+				t.Errorf("ETag = %q, want %q", got, etag)
+			}
+			if got := resp.Header.Get("Cache-Control"); got != imageCacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, imageCacheControl)
+			}
+		})
+	}
+
+	// 内容が変わった場合（ETag が一致しない）は 200 で返す
+	resp := getImage(h, http.Header{"If-None-Match": {`"other"`}})
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status with a stale ETag = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestGetImageRange(t *testing.T) {
+	h := newTestImageHandler(t)
+	resp := getImage(h, http.Header{"Range": {"bytes=2-5"}})
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("status = %d, want 206", resp.StatusCode)
+	}
+	want := "bytes 2-5/" + strconv.Itoa(len(testImage))
+	if got := resp.Header.Get("Content-Range"); got != want {
+		t.Errorf("Content-Range = %q, want %q", got, want)
+	}
+	if string(body) != string(testImage[2:6]) {
+		t.Errorf("body = %q, want %q", body, testImage[2:6])
+	}
+
+	// 範囲外は 416
+	resp = getImage(h, http.Header{"Range": {"bytes=1000-"}})
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("status for an unsatisfiable range = %d, want 416", resp.StatusCode)
+	}
+}
+
+func TestGetImageNotAllowed(t *testing.T) {
+	h := newTestImageHandler(t)
+	for _, path := range []string{"b.png", "../a.png", "/etc/passwd"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/image?path="+path, nil)
+		rec := httptest.NewRecorder()
+		h.GetImage(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("path %q: status = %d, want 404", path, rec.Code)
+		}
+	}
+}
@@ -1,12 +1,16 @@
 package handler
 
 import (
+	"backend/internal/apierror"
+	"backend/internal/logging"
 	"backend/internal/middleware"
 	"backend/internal/model"
 	"backend/internal/service"
 	"encoding/json"
-	"log"
 	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type OrderHandler struct {
@@ -21,13 +25,13 @@ func NewOrderHandler(svc *service.OrderService) *OrderHandler {
 func (h *OrderHandler) List(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User not found", http.StatusInternalServerError)
+		apierror.WriteError(w, r, errNoUserInContext)
 		return
 	}
 
 	var req model.ListRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.WriteError(w, r, errInvalidBody)
 		return
 	}
 
@@ -47,11 +51,17 @@ func (h *OrderHandler) List(w http.ResponseWriter, r *http.Request) {
 	if req.Type != "" && req.Type != "partial" && req.Type != "prefix" {
 		req.Type = "partial"
 	}
+	for _, st := range req.ShippedStatuses {
+		if !model.IsValidShippedStatus(st) {
+			writeBadRequest(w, r, "Invalid shipped_status: "+st)
+			return
+		}
+	}
 
 	orders, total, err := h.OrderSvc.FetchOrders(r.Context(), userID, req)
 	if err != nil {
-		log.Printf("Failed to fetch orders for user %d: %v", userID, err)
-		http.Error(w, "Failed to fetch orders", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("Failed to fetch orders", "user_id", userID, "error", err)
+		writeInternalError(w, r, "Failed to fetch orders")
 		return
 	}
 
@@ -66,3 +76,129 @@ func (h *OrderHandler) List(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+// 注文詳細をステータス変更履歴付きで取得
+func (h *OrderHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.WriteError(w, r, errNoUserInContext)
+		return
+	}
+
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeBadRequest(w, r, "Invalid order ID")
+		return
+	}
+
+	detail, err := h.OrderSvc.GetOrderDetail(r.Context(), userID, orderID)
+	if err != nil {
+		if writeServiceError(w, r, err) {
+			return
+		}
+		logging.FromContext(r.Context()).Error("Failed to fetch order", "order_id", orderID, "user_id", userID, "error", err)
+		writeInternalError(w, r, "Failed to fetch order")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// 注文をキャンセル
+func (h *OrderHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.WriteError(w, r, errNoUserInContext)
+		return
+	}
+
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeBadRequest(w, r, "Invalid order ID")
+		return
+	}
+
+	if err := h.OrderSvc.CancelOrder(r.Context(), userID, orderID); err != nil {
+		if !writeServiceError(w, r, err) {
+			logging.FromContext(r.Context()).Error("Failed to cancel order", "order_id", orderID, "user_id", userID, "error", err)
+			writeInternalError(w, r, "Failed to cancel order")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":        "Order cancelled",
+		"order_id":       orderID,
+		"shipped_status": model.StatusCancelled,
+	})
+}
+
+// 注文グループ（1回の購入）の一覧を取得
+func (h *OrderHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.WriteError(w, r, errNoUserInContext)
+		return
+	}
+
+	var req model.ListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.WriteError(w, r, errInvalidBody)
+		return
+	}
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+	req.Offset = (req.Page - 1) * req.PageSize
+
+	groups, total, err := h.OrderSvc.FetchOrderGroups(r.Context(), userID, req)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to fetch order groups", "user_id", userID, "error", err)
+		writeInternalError(w, r, "Failed to fetch order groups")
+		return
+	}
+
+	resp := struct {
+		Data  []model.OrderGroup `json:"data"`
+		Total int                `json:"total"`
+	}{
+		Data:  groups,
+		Total: total,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// 注文グループを1件取得
+func (h *OrderHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.WriteError(w, r, errNoUserInContext)
+		return
+	}
+
+	groupID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeBadRequest(w, r, "Invalid order group ID")
+		return
+	}
+
+	group, err := h.OrderSvc.GetOrderGroup(r.Context(), userID, groupID)
+	if err != nil {
+		if writeServiceError(w, r, err) {
+			return
+		}
+		logging.FromContext(r.Context()).Error("Failed to fetch order group", "group_id", groupID, "user_id", userID, "error", err)
+		writeInternalError(w, r, "Failed to fetch order group")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(group)
+}
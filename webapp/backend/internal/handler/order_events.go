@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/events"
+	"backend/internal/middleware"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// 接続を維持するためにコメント行を送る間隔
+	sseHeartbeatInterval = 15 * time.Second
+	// 切断時にクライアントが再接続するまでの待ち時間(ms)
+	sseRetryMillis = 3000
+)
+
+// 注文ステータスの変更を Server-Sent Events で配信する
+// 再接続時は Last-Event-ID ヘッダー以降の取りこぼしたイベントを先に送る
+func (h *OrderHandler) Events(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.WriteError(w, r, errNoUserInContext)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeInternalError(w, r, "Streaming unsupported")
+		return
+	}
+
+	var lastEventID uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeBadRequest(w, r, "Invalid Last-Event-ID")
+			return
+		}
+		lastEventID = id
+	}
+
+	sub, missed := h.OrderSvc.SubscribeStatusEvents(userID, lastEventID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// nginx でバッファリングされないようにする
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetryMillis)
+	for _, ev := range missed {
+		if err := writeStatusEvent(w, ev); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Dropped:
+			// 送信が追いつかず切断された。クライアントは Last-Event-ID で再接続する
+			return
+		case ev := <-sub.C:
+			if err := writeStatusEvent(w, ev); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeStatusEvent(w http.ResponseWriter, ev events.OrderStatusEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: order_status\ndata: %s\n\n", ev.ID, data)
+	return err
+}
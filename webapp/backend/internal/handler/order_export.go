@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/logging"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 何件書き出すごとにクライアントへフラッシュするか
+const exportFlushInterval = 1000
+
+// エクスポートする注文の1行
+type orderExportRow struct {
+	OrderID       int64      `json:"order_id"`
+	ProductID     int        `json:"product_id"`
+	ProductName   string     `json:"product_name"`
+	ShippedStatus string     `json:"shipped_status"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ArrivedAt     *time.Time `json:"arrived_at"`
+}
+
+func newOrderExportRow(o model.Order) orderExportRow {
+	row := orderExportRow{
+		OrderID:       o.OrderID,
+		ProductID:     o.ProductID,
+		ProductName:   o.ProductName,
+		ShippedStatus: o.ShippedStatus,
+		CreatedAt:     o.CreatedAt,
+	}
+	if o.ArrivedAt.Valid {
+		arrivedAt := o.ArrivedAt.Time
+		row.ArrivedAt = &arrivedAt
+	}
+	return row
+}
+
+// 注文履歴を CSV または JSON Lines で書き出す
+// 絞り込み条件は /api/v1/orders と同じ項目をクエリパラメータで受け取る
+func (h *OrderHandler) Export(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.WriteError(w, r, errNoUserInContext)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "jsonl" {
+		writeBadRequest(w, r, "Query parameter 'format' must be 'csv' or 'jsonl'")
+		return
+	}
+
+	req, err := parseOrderFilterQuery(r)
+	if err != nil {
+		writeBadRequest(w, r, err.Error())
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	filename := "orders." + format
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	var write func(model.Order) error
+	var flush func() error
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"order_id", "product_id", "product_name", "shipped_status", "created_at", "arrived_at"}); err != nil {
+			return
+		}
+		write = func(o model.Order) error {
+			row := newOrderExportRow(o)
+			arrivedAt := ""
+			if row.ArrivedAt != nil {
+				arrivedAt = row.ArrivedAt.Format(time.RFC3339)
+			}
+			return cw.Write([]string{
+				strconv.FormatInt(row.OrderID, 10),
+				strconv.Itoa(row.ProductID),
+				row.ProductName,
+				row.ShippedStatus,
+				row.CreatedAt.Format(time.RFC3339),
+				arrivedAt,
+			})
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case "jsonl":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		write = func(o model.Order) error {
+			return enc.Encode(newOrderExportRow(o))
+		}
+		flush = func() error { return nil }
+	}
+
+	n := 0
+	err = h.OrderSvc.ExportOrders(r.Context(), userID, req, func(o model.Order) error {
+		if err := write(o); err != nil {
+			return err
+		}
+		n++
+		if n%exportFlushInterval == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if ferr := flush(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		// 書き出しを始めた後はステータスコードを変更できないため、ログに残して打ち切る
+		logging.FromContext(r.Context()).Error("Failed to export orders", "user_id", userID, "rows", n, "error", err)
+	}
+}
+
+// クエリパラメータから注文履歴の絞り込み条件を組み立てる
+func parseOrderFilterQuery(r *http.Request) (model.ListRequest, error) {
+	q := r.URL.Query()
+	req := model.ListRequest{
+		Search: q.Get("search"),
+		Type:   q.Get("type"),
+	}
+	if req.Type != "" && req.Type != "partial" && req.Type != "prefix" {
+		req.Type = "partial"
+	}
+
+	for _, st := range q["shipped_status"] {
+		if !model.IsValidShippedStatus(st) {
+			return req, fmt.Errorf("Invalid shipped_status: %s", st)
+		}
+		req.ShippedStatuses = append(req.ShippedStatuses, st)
+	}
+
+	if v := q.Get("product_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return req, fmt.Errorf("Query parameter 'product_id' must be an integer")
+		}
+		req.ProductID = &id
+	}
+
+	times := []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"created_from", &req.CreatedFrom},
+		{"created_to", &req.CreatedTo},
+		{"arrived_from", &req.ArrivedFrom},
+		{"arrived_to", &req.ArrivedTo},
+	}
+	for _, t := range times {
+		v := q.Get(t.name)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return req, fmt.Errorf("Query parameter '%s' must be an RFC 3339 timestamp", t.name)
+		}
+		*t.dst = &parsed
+	}
+	return req, nil
+}
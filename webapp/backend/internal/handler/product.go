@@ -1,37 +1,59 @@
 package handler
 
 import (
+	"backend/internal/apierror"
+	"backend/internal/imagestore"
+	"backend/internal/logging"
 	"backend/internal/middleware"
 	"backend/internal/model"
 	"backend/internal/service"
+	"backend/internal/thumbnail"
 	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
+	"io"
 	"net/http"
-	"os"
-	"path/filepath"
-	"strings"
 )
 
+// Idempotency-Key の最大長（idempotency_keys.idempotency_key の列長）
+const maxIdempotencyKeyLength = 255
+
+// 画像の Cache-Control
+// パスは同じまま画像が差し替えられることがあるため、期限後は ETag で再検証させる
+const imageCacheControl = "public, max-age=3600, must-revalidate"
+
 type ProductHandler struct {
 	ProductSvc *service.ProductService
+	imageETags *imageETagCache
+	// 配信してよい画像のパス（LoadImageAllowlist で読み込む）
+	imageAllowlist imageAllowlist
+	// nil の場合は imageDir のファイルをそのまま配信する
+	images   *imagestore.Store
+	imageDir string
+	// nil の場合は w / h を無視して元の画像を返す
+	thumbnails *thumbnail.Generator
 }
 
-func NewProductHandler(svc *service.ProductService) *ProductHandler {
-	return &ProductHandler{ProductSvc: svc}
+func NewProductHandler(svc *service.ProductService, images *imagestore.Store, thumbnails *thumbnail.Generator) *ProductHandler {
+	return &ProductHandler{
+		ProductSvc: svc,
+		imageETags: newImageETagCache(),
+		images:     images,
+		imageDir:   defaultImageDir,
+		thumbnails: thumbnails,
+	}
 }
 
 // 商品一覧を取得
 func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		apierror.WriteError(w, r, errNoUserInContext)
 		return
 	}
 
 	var req model.ListRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.WriteError(w, r, errInvalidBody)
 		return
 	}
 
@@ -51,8 +73,8 @@ func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	products, total, err := h.ProductSvc.FetchProducts(r.Context(), userID, req)
 	if err != nil {
-		log.Printf("Failed to fetch products for user %d: %v", userID, err)
-		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("Failed to fetch products", "user_id", userID, "error", err)
+		writeInternalError(w, r, "Failed to fetch products")
 		return
 	}
 
@@ -72,79 +94,123 @@ func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
 func (h *ProductHandler) CreateOrders(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		apierror.WriteError(w, r, errNoUserInContext)
 		return
 	}
 
 	var req model.CreateOrderRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.WriteError(w, r, errInvalidBody)
+		return
+	}
+
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		h.createOrdersIdempotent(w, r, userID, key, req.Items)
+		return
+	}
+
+	result, err := h.ProductSvc.CreateOrders(r.Context(), userID, req.Items)
+	if err != nil {
+		if writeServiceError(w, r, err) {
+			return
+		}
+		logging.FromContext(r.Context()).Error("Failed to create orders", "user_id", userID, "error", err)
+		writeInternalError(w, r, "Failed to process order request")
+		return
+	}
+
+	writeCreateOrdersResponse(w, result)
+}
+
+// Idempotency-Key 付きの注文作成
+// 再送されたリクエストには最初のリクエストと同じステータスコードと注文IDを返す
+func (h *ProductHandler) createOrdersIdempotent(w http.ResponseWriter, r *http.Request, userID int, key string, items []model.RequestItem) {
+	if len(key) > maxIdempotencyKeyLength {
+		writeBadRequest(w, r, "Idempotency-Key is too long")
 		return
 	}
 
-	insertedOrderIDs, err := h.ProductSvc.CreateOrders(r.Context(), userID, req.Items)
+	result, err := h.ProductSvc.CreateOrdersIdempotent(r.Context(), userID, key, items)
 	if err != nil {
-		log.Printf("Failed to create orders: %v", err)
-		http.Error(w, "Failed to process order request", http.StatusInternalServerError)
+		if !writeServiceError(w, r, err) {
+			logging.FromContext(r.Context()).Error("Failed to create orders", "user_id", userID, "error", err)
+			writeInternalError(w, r, "Failed to process order request")
+		}
 		return
 	}
 
+	if result.Replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	writeCreateOrdersResponse(w, result)
+}
+
+func writeCreateOrdersResponse(w http.ResponseWriter, result *service.CreateOrdersResult) {
 	response := map[string]interface{}{
 		"message":   "Orders created successfully",
-		"order_ids": insertedOrderIDs,
+		"order_ids": result.OrderIDs,
+	}
+	if result.GroupID != 0 {
+		response["group_id"] = result.GroupID
 	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(result.StatusCode)
 	json.NewEncoder(w).Encode(response)
 }
 
 func (h *ProductHandler) GetImage(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("画像リクエスト受信: %s\n", r.URL.String())
+	logger := logging.FromContext(r.Context())
+	logger.Debug("画像リクエスト受信", "url", r.URL.String())
 	imagePath := r.URL.Query().Get("path")
 	if imagePath == "" {
-		fmt.Println("画像パスが空です")
-		http.Error(w, "画像パスが指定されていません", http.StatusBadRequest)
+		logger.Info("画像パスが空です")
+		writeBadRequest(w, r, "Query parameter 'path' is required")
 		return
 	}
 
-	imagePath = filepath.Clean(imagePath)
-	if filepath.IsAbs(imagePath) || strings.Contains(imagePath, "..") {
-		fmt.Printf("無効なパス: %s\n", imagePath)
-		http.Error(w, "無効なパスです", http.StatusBadRequest)
+	// 商品が参照している画像だけを配信する（それ以外は存在しないものとして扱う）
+	imagePath, ok := imageKey(imagePath)
+	if !ok || !h.imageAllowlist.contains(imagePath) {
+		logger.Info("配信対象外の画像です", "path", r.URL.Query().Get("path"))
+		writeServiceError(w, r, imagestore.ErrNotFound)
 		return
 	}
 
-	baseImageDir := "/app/images"
-	fullPath := filepath.Join(baseImageDir, imagePath)
-
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		fmt.Printf("画像ファイルが見つかりません: %s\n", fullPath)
-		http.Error(w, "画像が見つかりません", http.StatusNotFound)
+	// w / h を指定した場合だけ縮小画像を返す（指定がなければ元の画像のまま）
+	width, height, err := parseThumbnailSize(r)
+	if err != nil {
+		writeBadRequest(w, r, err.Error())
 		return
 	}
 
-	ext := filepath.Ext(fullPath)
-	var contentType string
-	switch strings.ToLower(ext) {
-	case ".jpg", ".jpeg":
-		contentType = "image/jpeg"
-	case ".png":
-		contentType = "image/png"
-	case ".gif":
-		contentType = "image/gif"
-	case ".webp":
-		contentType = "image/webp"
-	default:
-		contentType = "application/octet-stream"
-	}
-	w.Header().Set("Content-Type", contentType)
-
-	data, err := os.ReadFile(fullPath)
+	src, err := h.openImage(r, imagePath)
 	if err != nil {
-		fmt.Printf("画像ファイルの読み込みに失敗: %s\n", fullPath)
-		http.Error(w, "画像の読み込みに失敗しました", http.StatusInternalServerError)
+		if errors.Is(err, imagestore.ErrNotFound) {
+			logger.Info("画像が見つかりません", "path", imagePath)
+			writeServiceError(w, r, err)
+			return
+		}
+		logger.Error("画像の読み込みに失敗", "path", imagePath, "error", err)
+		writeInternalError(w, r, "Failed to load image")
 		return
 	}
+	defer src.content.Close()
+
+	if (width > 0 || height > 0) && h.thumbnails != nil {
+		if h.serveThumbnail(w, r, src, width, height) {
+			return
+		}
+		// 縮小できない画像は元の画像を返す
+		if _, err := src.content.Seek(0, io.SeekStart); err != nil {
+			writeInternalError(w, r, "Failed to load image")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", src.contentType)
+	w.Header().Set("ETag", `"`+src.hash+`"`)
+	w.Header().Set("Cache-Control", imageCacheControl)
 
-	w.Write(data)
+	// If-None-Match / If-Modified-Since による 304 と Range リクエストは ServeContent が処理する
+	http.ServeContent(w, r, src.name, src.modTime, src.content)
 }
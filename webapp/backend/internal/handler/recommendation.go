@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/logging"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/service"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type RecommendationHandler struct {
+	RecommendSvc *service.RecommendationService
+}
+
+func NewRecommendationHandler(svc *service.RecommendationService) *RecommendationHandler {
+	return &RecommendationHandler{RecommendSvc: svc}
+}
+
+// 商品と一緒に購入されている商品を取得
+func (h *RecommendationHandler) Related(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeBadRequest(w, r, "Invalid product ID")
+		return
+	}
+	limit, ok := parseLimit(w, r)
+	if !ok {
+		return
+	}
+
+	products, err := h.RecommendSvc.Related(r.Context(), productID, limit)
+	if err != nil {
+		if writeServiceError(w, r, err) {
+			return
+		}
+		logging.FromContext(r.Context()).Error("Failed to fetch related products", "product_id", productID, "error", err)
+		writeInternalError(w, r, "Failed to fetch related products")
+		return
+	}
+
+	writeRecommendations(w, products)
+}
+
+// ログイン中のユーザーへのおすすめ商品を取得
+func (h *RecommendationHandler) ForUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.WriteError(w, r, errNoUserInContext)
+		return
+	}
+	limit, ok := parseLimit(w, r)
+	if !ok {
+		return
+	}
+
+	products, err := h.RecommendSvc.ForUser(r.Context(), userID, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to fetch recommendations", "user_id", userID, "error", err)
+		writeInternalError(w, r, "Failed to fetch recommendations")
+		return
+	}
+
+	writeRecommendations(w, products)
+}
+
+// クエリパラメータ limit を読む（未指定の場合は 0）
+func parseLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return 0, true
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil {
+		writeBadRequest(w, r, "Query parameter 'limit' must be an integer")
+		return 0, false
+	}
+	return limit, true
+}
+
+func writeRecommendations(w http.ResponseWriter, products []model.RecommendedProduct) {
+	resp := struct {
+		Data []model.RecommendedProduct `json:"data"`
+	}{
+		Data: products,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -1,14 +1,18 @@
 package handler
 
 import (
+	"backend/internal/apierror"
+	"backend/internal/logging"
 	"backend/internal/model"
 	"backend/internal/service"
 	"encoding/json"
-	"log"
 	"net/http"
 	"strconv"
 )
 
+// ロボットを指定しないリクエストで使うロボットID
+const defaultRobotID = "robot-001"
+
 type RobotHandler struct {
 	RobotSvc *service.RobotService
 }
@@ -19,23 +23,26 @@ func NewRobotHandler(robotSvc *service.RobotService) *RobotHandler {
 
 // 配送計画を取得
 func (h *RobotHandler) GetDeliveryPlan(w http.ResponseWriter, r *http.Request) {
-	robotID := "robot-001"
+	robotID := defaultRobotID
 
 	capacityStr := r.URL.Query().Get("capacity")
 	if capacityStr == "" {
-		http.Error(w, "Query parameter 'capacity' is required", http.StatusBadRequest)
+		writeBadRequest(w, r, "Query parameter 'capacity' is required")
 		return
 	}
 	capacity, err := strconv.Atoi(capacityStr)
 	if err != nil {
-		http.Error(w, "Query parameter 'capacity' must be an integer", http.StatusBadRequest)
+		writeBadRequest(w, r, "Query parameter 'capacity' must be an integer")
 		return
 	}
 
 	plan, err := h.RobotSvc.GenerateDeliveryPlan(r.Context(), robotID, capacity)
 	if err != nil {
-		log.Printf("Failed to generate delivery plan: %v", err)
-		http.Error(w, "Failed to create delivery plan", http.StatusInternalServerError)
+		if writeServiceError(w, r, err) {
+			return
+		}
+		logging.FromContext(r.Context()).Error("Failed to generate delivery plan", "robot_id", robotID, "capacity", capacity, "error", err)
+		writeInternalError(w, r, "Failed to create delivery plan")
 		return
 	}
 
@@ -47,14 +54,21 @@ func (h *RobotHandler) GetDeliveryPlan(w http.ResponseWriter, r *http.Request) {
 func (h *RobotHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 	var req model.UpdateOrderStatusRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.WriteError(w, r, errInvalidBody)
 		return
 	}
 
-	err := h.RobotSvc.UpdateOrderStatus(r.Context(), req.OrderID, req.NewStatus)
+	robotID := req.RobotID
+	if robotID == "" {
+		robotID = defaultRobotID
+	}
+	err := h.RobotSvc.UpdateOrderStatus(r.Context(), robotID, req.OrderID, req.NewStatus)
 	if err != nil {
-		log.Printf("Failed to update order status for order %d: %v", req.OrderID, err)
-		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+		if writeServiceError(w, r, err) {
+			return
+		}
+		logging.FromContext(r.Context()).Error("Failed to update order status", "robot_id", robotID, "order_id", req.OrderID, "status", req.NewStatus, "error", err)
+		writeInternalError(w, r, "Failed to update order status")
 		return
 	}
 
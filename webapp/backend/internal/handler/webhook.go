@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"backend/internal/apierror"
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/service"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type WebhookHandler struct {
+	WebhookSvc *service.WebhookService
+}
+
+func NewWebhookHandler(svc *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{WebhookSvc: svc}
+}
+
+// 通知先を登録
+// 応答に含まれる secret は署名の検証に使う。以降の一覧では返さない
+func (h *WebhookHandler) CreateEndpoint(w http.ResponseWriter, r *http.Request) {
+	var req model.CreateWebhookEndpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.WriteError(w, r, errInvalidBody)
+		return
+	}
+
+	ep, err := h.WebhookSvc.CreateEndpoint(r.Context(), req)
+	if err != nil {
+		if writeServiceError(w, r, err) {
+			return
+		}
+		logging.FromContext(r.Context()).Error("Failed to create webhook endpoint", "error", err)
+		writeInternalError(w, r, "Failed to create webhook endpoint")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ep)
+}
+
+// 通知先の一覧を取得
+func (h *WebhookHandler) ListEndpoints(w http.ResponseWriter, r *http.Request) {
+	endpoints, err := h.WebhookSvc.ListEndpoints(r.Context())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list webhook endpoints", "error", err)
+		writeInternalError(w, r, "Failed to list webhook endpoints")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(endpoints)
+}
+
+// 通知先を無効化
+func (h *WebhookHandler) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpointID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeBadRequest(w, r, "Invalid endpoint ID")
+		return
+	}
+
+	if err := h.WebhookSvc.DeactivateEndpoint(r.Context(), endpointID); err != nil {
+		if writeServiceError(w, r, err) {
+			return
+		}
+		logging.FromContext(r.Context()).Error("Failed to deactivate webhook endpoint", "endpoint_id", endpointID, "error", err)
+		writeInternalError(w, r, "Failed to deactivate webhook endpoint")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 通知先の配送ログを取得
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	endpointID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeBadRequest(w, r, "Invalid endpoint ID")
+		return
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil {
+			writeBadRequest(w, r, "Query parameter 'limit' must be an integer")
+			return
+		}
+	}
+
+	deliveries, err := h.WebhookSvc.ListDeliveries(r.Context(), endpointID, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list webhook deliveries", "endpoint_id", endpointID, "error", err)
+		writeInternalError(w, r, "Failed to list webhook deliveries")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// 配送の試行ログを取得
+func (h *WebhookHandler) ListAttempts(w http.ResponseWriter, r *http.Request) {
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil {
+		writeBadRequest(w, r, "Invalid delivery ID")
+		return
+	}
+
+	attempts, err := h.WebhookSvc.ListAttempts(r.Context(), deliveryID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to list webhook attempts", "delivery_id", deliveryID, "error", err)
+		writeInternalError(w, r, "Failed to list webhook attempts")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attempts)
+}
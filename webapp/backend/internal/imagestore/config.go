@@ -0,0 +1,44 @@
+package imagestore
+
+import (
+	"backend/internal/config"
+	"fmt"
+	"strings"
+)
+
+// 設定から保存先を組み立てる
+// cfg.Kind は "fs" または "s3"（空の場合は nil を返し、ストアを使わない）
+func NewBackend(cfg config.ImageStore) (Backend, error) {
+	switch cfg.Kind {
+	case "":
+		return nil, nil
+	case "fs":
+		return NewFSBackend(cfg.Dir)
+	case "s3":
+		return NewS3Backend(S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Bucket:    cfg.S3.Bucket,
+			Region:    cfg.S3.Region,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unknown image store %q (want fs or s3)", cfg.Kind)
+	}
+}
+
+// 拡張子から画像の Content-Type を決める
+func ContentTypeByExt(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	default:
+		return "application/octet-stream"
+	}
+}
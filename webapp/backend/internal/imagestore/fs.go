@@ -0,0 +1,102 @@
+package imagestore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ローカルのディレクトリに保存する Backend
+// 1ディレクトリのファイル数が増えすぎないよう、<root>/<hash[0:2]>/<hash[2:4]>/<hash> に置く
+type FSBackend struct {
+	root string
+}
+
+func NewFSBackend(root string) (*FSBackend, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &FSBackend{root: root}, nil
+}
+
+func (b *FSBackend) blobPath(hash string) string {
+	return filepath.Join(b.root, hash[0:2], hash[2:4], hash)
+}
+
+func (b *FSBackend) Open(ctx context.Context, hash string) (io.ReadSeekCloser, error) {
+	if !validHash(hash) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(b.blobPath(hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func (b *FSBackend) Exists(ctx context.Context, hash string) (bool, error) {
+	if !validHash(hash) {
+		return false, nil
+	}
+	_, err := os.Stat(b.blobPath(hash))
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// 一時ファイルに書き込んでから名前を変え、書きかけのファイルを読まれないようにする
+func (b *FSBackend) Put(ctx context.Context, hash string, r io.Reader, size int64, contentType string) error {
+	if !validHash(hash) {
+		return errInvalidHash
+	}
+	dst := b.blobPath(hash)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), hash+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+var errInvalidHash = errors.New("invalid image hash")
+
+// SHA-256 の16進表記かどうか（パスの組み立てに使うため検証する）
+func validHash(hash string) bool {
+	if len(hash) != 64 {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
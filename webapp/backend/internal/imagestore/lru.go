@@ -0,0 +1,86 @@
+package imagestore
+
+import (
+	"container/list"
+	"sync"
+)
+
+// 画像の内容を合計サイズの上限まで保持する LRU
+type blobLRU struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	ll       *list.List
+	items    map[string]*list.Element
+	hits     uint64
+	misses   uint64
+}
+
+type blobEntry struct {
+	hash string
+	data []byte
+}
+
+func newBlobLRU(maxBytes int64) *blobLRU {
+	return &blobLRU{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// 内容は同じハッシュで共有されるため、呼び出し側で書き換えないこと
+func (c *blobLRU) get(hash string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[hash]; ok {
+		c.ll.MoveToFront(el)
+		c.hits++
+		return el.Value.(*blobEntry).data, true
+	}
+	c.misses++
+	return nil, false
+}
+
+func (c *blobLRU) add(hash string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if int64(len(data)) > c.maxBytes {
+		return
+	}
+	if el, ok := c.items[hash]; ok {
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[hash] = c.ll.PushFront(&blobEntry{hash: hash, data: data})
+	c.size += int64(len(data))
+
+	for c.size > c.maxBytes {
+		el := c.ll.Back()
+		e := el.Value.(*blobEntry)
+		c.ll.Remove(el)
+		delete(c.items, e.hash)
+		c.size -= int64(len(e.data))
+	}
+}
+
+// メモリ上の画像キャッシュの統計情報
+type CacheStats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"`
+	Bytes   int64  `json:"bytes"`
+}
+
+func (c *blobLRU) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.ll.Len(), Bytes: c.size}
+}
+
+// メモリ上の画像キャッシュの統計情報を取得
+func (s *Store) CacheStats() CacheStats {
+	return s.blobs.stats()
+}
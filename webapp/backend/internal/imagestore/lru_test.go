@@ -0,0 +1,34 @@
+package imagestore
+
+import "testing"
+
+func TestBlobLRU(t *testing.T) {
+	c := newBlobLRU(10)
+	c.add("a", make([]byte, 4))
+	c.add("b", make([]byte, 4))
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("a is not cached")
+	}
+	// 上限を超えると、最も長く使われていない b が削除される
+	c.add("c", make([]byte, 4))
+	if _, ok := c.get("b"); ok {
+		t.Error("b is still cached, want it evicted")
+	}
+	for _, hash := range []string{"a", "c"} {
+		if _, ok := c.get(hash); !ok {
+			t.Errorf("%s is not cached", hash)
+		}
+	}
+	// 同じハッシュを追加し直しても二重に数えず、上限より大きな内容は載せない
+	c.add("a", make([]byte, 4))
+	c.add("big", make([]byte, 11))
+	if _, ok := c.get("big"); ok {
+		t.Error("big is cached, want it skipped")
+	}
+
+	got := c.stats()
+	want := CacheStats{Hits: 3, Misses: 2, Entries: 2, Bytes: 8}
+	if got != want {
+		t.Errorf("stats() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,185 @@
+// 画像を内容のハッシュ(SHA-256)で管理するストア
+//
+// 商品画像の多くは同じファイルのコピーのため、内容ごとに1つだけ保存し、
+// パス（products.image）からハッシュを引いて配信する。
+// よく使われる画像はメモリ上の LRU に載せ、保存先（Backend）への読み込みを減らす
+package imagestore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/internal/model"
+)
+
+var ErrNotFound = errors.New("image not found")
+
+const (
+	// パスとハッシュの対応をメモリに保持する時間
+	// 取り込みで付け替えられたパスはこの時間が経つと反映される
+	pathCacheTTL = 5 * time.Minute
+	// メモリに載せる画像の合計サイズと、1画像あたりの上限
+	defaultCacheBytes  = 64 << 20
+	maxCachedBlobBytes = 4 << 20
+)
+
+// 画像の保存先
+type Backend interface {
+	// 内容を読み出す。存在しない場合は ErrNotFound を返す
+	Open(ctx context.Context, hash string) (io.ReadSeekCloser, error)
+	Exists(ctx context.Context, hash string) (bool, error)
+	// 内容を保存する。r の内容のハッシュが hash と一致することは呼び出し側で保証する
+	Put(ctx context.Context, hash string, r io.Reader, size int64, contentType string) error
+}
+
+// パスとハッシュの対応の保存先（repository.ImageRepository）
+type Index interface {
+	FindByPath(ctx context.Context, path string) (*model.ImageObject, error)
+	SaveObject(ctx context.Context, obj model.ImageObject) error
+	SavePath(ctx context.Context, path, hash string) error
+}
+
+type Store struct {
+	backend Backend
+	index   Index
+	blobs   *blobLRU
+
+	mu    sync.Mutex
+	paths map[string]cachedPath
+}
+
+type cachedPath struct {
+	obj       model.ImageObject
+	expiresAt time.Time
+}
+
+// cacheBytes が 0 以下の場合は既定値（64MB）を使う
+func New(backend Backend, index Index, cacheBytes int64) *Store {
+	if cacheBytes <= 0 {
+		cacheBytes = defaultCacheBytes
+	}
+	return &Store{
+		backend: backend,
+		index:   index,
+		blobs:   newBlobLRU(cacheBytes),
+		paths:   make(map[string]cachedPath),
+	}
+}
+
+// 配信する画像
+// Content は Close する必要がある
+type Object struct {
+	model.ImageObject
+	Content io.ReadSeekCloser
+}
+
+// パスの画像を開く
+// 登録されていないパスの場合は ErrNotFound を返す
+func (s *Store) Open(ctx context.Context, p string) (*Object, error) {
+	obj, err := s.lookup(ctx, NormalizePath(p))
+	if err != nil {
+		return nil, err
+	}
+
+	if data, ok := s.blobs.get(obj.Hash); ok {
+		return &Object{ImageObject: *obj, Content: nopCloser{bytes.NewReader(data)}}, nil
+	}
+
+	rc, err := s.backend.Open(ctx, obj.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Size > maxCachedBlobBytes {
+		return &Object{ImageObject: *obj, Content: rc}, nil
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, err
+	}
+	s.blobs.add(obj.Hash, data)
+	return &Object{ImageObject: *obj, Content: nopCloser{bytes.NewReader(data)}}, nil
+}
+
+func (s *Store) lookup(ctx context.Context, p string) (*model.ImageObject, error) {
+	s.mu.Lock()
+	c, ok := s.paths[p]
+	s.mu.Unlock()
+	if ok && time.Now().Before(c.expiresAt) {
+		return &c.obj, nil
+	}
+
+	obj, err := s.index.FindByPath(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, ErrNotFound
+	}
+
+	s.mu.Lock()
+	s.paths[p] = cachedPath{obj: *obj, expiresAt: time.Now().Add(pathCacheTTL)}
+	s.mu.Unlock()
+	return obj, nil
+}
+
+// 取り込みの結果
+// Deduplicated は同じ内容の画像が既に保存されていた場合に true
+type ImportResult struct {
+	Hash         string
+	Size         int64
+	Deduplicated bool
+}
+
+// 画像を取り込み、パスに対応付ける
+// 内容のハッシュを先に計算し、保存されていない内容の場合だけ保存先に書き込む
+func (s *Store) Import(ctx context.Context, p string, r io.ReadSeeker, contentType string) (*ImportResult, error) {
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	exists, err := s.backend.Exists(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := s.backend.Put(ctx, hash, r, size, contentType); err != nil {
+			return nil, err
+		}
+	}
+
+	obj := model.ImageObject{Hash: hash, Size: size, ContentType: contentType, CreatedAt: time.Now()}
+	if err := s.index.SaveObject(ctx, obj); err != nil {
+		return nil, err
+	}
+	if err := s.index.SavePath(ctx, NormalizePath(p), hash); err != nil {
+		return nil, err
+	}
+	return &ImportResult{Hash: hash, Size: size, Deduplicated: exists}, nil
+}
+
+// パスを登録時と同じ形にそろえる（区切りは "/"、先頭の "/" や "./" は付けない）
+func NormalizePath(p string) string {
+	p = strings.ReplaceAll(p, "\\", "/")
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
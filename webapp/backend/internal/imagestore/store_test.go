@@ -0,0 +1,118 @@
+package imagestore
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"backend/internal/model"
+)
+
+// パスとハッシュの対応をメモリに保持する Index
+type memIndex struct {
+	objects map[string]model.ImageObject
+	paths   map[string]string
+}
+
+func newMemIndex() *memIndex {
+	return &memIndex{objects: make(map[string]model.ImageObject), paths: make(map[string]string)}
+}
+
+func (m *memIndex) FindByPath(ctx context.Context, path string) (*model.ImageObject, error) {
+	hash, ok := m.paths[path]
+	if !ok {
+		return nil, nil
+	}
+	obj := m.objects[hash]
+	return &obj, nil
+}
+
+func (m *memIndex) SaveObject(ctx context.Context, obj model.ImageObject) error {
+	if _, ok := m.objects[obj.Hash]; !ok {
+		m.objects[obj.Hash] = obj
+	}
+	return nil
+}
+
+func (m *memIndex) SavePath(ctx context.Context, path, hash string) error {
+	m.paths[path] = hash
+	return nil
+}
+
+// 保存先への書き込みと読み込みを数える Backend
+type countingBackend struct {
+	Backend
+	puts, opens int
+}
+
+func (b *countingBackend) Put(ctx context.Context, hash string, r io.Reader, size int64, contentType string) error {
+	b.puts++
+	return b.Backend.Put(ctx, hash, r, size, contentType)
+}
+
+func (b *countingBackend) Open(ctx context.Context, hash string) (io.ReadSeekCloser, error) {
+	b.opens++
+	return b.Backend.Open(ctx, hash)
+}
+
+func TestImportDeduplicates(t *testing.T) {
+	fs, err := NewFSBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend := &countingBackend{Backend: fs}
+	s := New(backend, newMemIndex(), 0)
+	ctx := context.Background()
+
+	first, err := s.Import(ctx, "/images/a.png", strings.NewReader("image"), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Import(ctx, `images\copy.png`, strings.NewReader("image"), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Deduplicated || !second.Deduplicated || first.Hash != second.Hash || second.Size != 5 {
+		t.Fatalf("Import() = %+v, %+v; want the second deduplicated with the same hash", first, second)
+	}
+	if backend.puts != 1 {
+		t.Errorf("puts = %d, want 1", backend.puts)
+	}
+
+	// どちらのパスも同じ内容を指し、2回目以降はメモリ上のキャッシュから返す
+	for _, p := range []string{"images/a.png", "./images/copy.png", "images/a.png"} {
+		obj, err := s.Open(ctx, p)
+		if err != nil {
+			t.Fatalf("Open(%q): %v", p, err)
+		}
+		data, _ := io.ReadAll(obj.Content)
+		obj.Content.Close()
+		if string(data) != "image" || obj.ContentType != "image/png" {
+			t.Errorf("Open(%q) = %q, %s", p, data, obj.ContentType)
+		}
+	}
+	if backend.opens != 1 {
+		t.Errorf("backend opens = %d, want 1", backend.opens)
+	}
+	if _, err := s.Open(ctx, "images/missing.png"); err != ErrNotFound {
+		t.Errorf("Open(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	tests := map[string]string{
+		"images/a.png":        "images/a.png",
+		"/images/a.png":       "images/a.png",
+		"./images/a.png":      "images/a.png",
+		`images\sub\a.png`:    "images/sub/a.png",
+		"images/../a.png":     "a.png",
+		"../../etc/passwd":    "etc/passwd",
+		"images//sub/./a.png": "images/sub/a.png",
+	}
+	for in, want := range tests {
+		if got := NormalizePath(in); got != want {
+			t.Errorf("NormalizePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// 構造化ログ
+//
+// ログは log/slog で JSON に書き出す。Setup で既定のロガーを差し替えるため、
+// log パッケージの出力も同じ形式になる。
+// リクエストの処理中は FromContext で、リクエストIDとトレースIDの付いたロガーを取得する。
+package logging
+
+import (
+	"backend/internal/config"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// config.Log の設定で既定のロガーを作り直す
+func Setup(cfg config.Log) {
+	slog.SetDefault(New(os.Stderr, cfg))
+}
+
+func New(w io.Writer, cfg config.Log) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+	var h slog.Handler = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})
+	if cfg.SampleInitial > 0 {
+		h = newSampler(h, cfg.SampleInitial, cfg.SampleThereafter)
+	}
+	return slog.New(h)
+}
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+)
+
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// コンテキストのロガーを返す
+// リクエストの外（バックグラウンドの処理など）では既定のロガーを返す
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// リクエストIDを返す（リクエストの外では空）
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
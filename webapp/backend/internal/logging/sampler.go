@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// debug / info のログをメッセージごとに間引く
+// 1秒ごとに、最初の initial 件はそのまま出し、それ以降は thereafter 件に1件だけ出す
+// warn 以上のログは間引かない
+type sampler struct {
+	next  slog.Handler
+	state *sampleState
+}
+
+type sampleState struct {
+	initial, thereafter int
+
+	mu     sync.Mutex
+	second int64
+	// その秒にメッセージごとに出そうとした件数
+	counts map[string]int
+}
+
+func newSampler(next slog.Handler, initial, thereafter int) *sampler {
+	return &sampler{
+		next:  next,
+		state: &sampleState{initial: initial, thereafter: thereafter, counts: make(map[string]int)},
+	}
+}
+
+func (s *sampler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.next.Enabled(ctx, level)
+}
+
+func (s *sampler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelWarn && !s.state.allow(r.Message, r.Time.Unix()) {
+		return nil
+	}
+	return s.next.Handle(ctx, r)
+}
+
+func (s *sampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sampler{next: s.next.WithAttrs(attrs), state: s.state}
+}
+
+func (s *sampler) WithGroup(name string) slog.Handler {
+	return &sampler{next: s.next.WithGroup(name), state: s.state}
+}
+
+func (s *sampleState) allow(msg string, second int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// log パッケージから来たメッセージは値を含むため、秒が変わるたびに数え直して増え続けないようにする
+	if second != s.second {
+		s.second = second
+		clear(s.counts)
+	}
+	s.counts[msg]++
+	n := s.counts[msg]
+	if n <= s.initial {
+		return true
+	}
+	return s.thereafter > 0 && (n-s.initial)%s.thereafter == 0
+}
@@ -2,9 +2,10 @@ package middleware
 
 import (
 	"context"
-	"log"
 	"net/http"
 
+	"backend/internal/apierror"
+	"backend/internal/logging"
 	"backend/internal/repository"
 )
 
@@ -17,16 +18,16 @@ func UserAuthMiddleware(sessionRepo *repository.SessionRepository) func(http.Han
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			cookie, err := r.Cookie("session_id")
 			if err != nil {
-				log.Printf("Error retrieving session cookie: %v", err)
-				http.Error(w, "Unauthorized: No session cookie", http.StatusUnauthorized)
+				logging.FromContext(r.Context()).Info("No session cookie", "error", err)
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "No session cookie")
 				return
 			}
 			sessionID := cookie.Value
 
 			userID, err := sessionRepo.FindUserBySessionID(r.Context(), sessionID)
 			if err != nil {
-				log.Printf("Error finding user by session ID: %v", err)
-				http.Error(w, "Unauthorized: Invalid session", http.StatusUnauthorized)
+				logging.FromContext(r.Context()).Info("Invalid session", "error", err)
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Invalid session")
 				return
 			}
 
@@ -42,7 +43,7 @@ func RobotAuthMiddleware(validAPIKey string) func(http.Handler) http.Handler {
 			apiKey := r.Header.Get("X-API-KEY")
 
 			if apiKey == "" || apiKey != validAPIKey {
-				http.Error(w, "Forbidden: Invalid or missing API key", http.StatusForbidden)
+				apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, "Invalid or missing API key")
 				return
 			}
 			next.ServeHTTP(w, r)
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"backend/internal/logging"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// 受け取るリクエストIDの最大長
+const maxRequestIDLength = 128
+
+// リクエストIDを割り当てる
+// クライアントやプロキシが X-Request-ID を付けていればそれを使い、なければ新しく作る（レスポンスにも付ける）
+// リクエストIDとトレースIDを付けたロガーをコンテキストに入れるため、otelchi のミドルウェアより後に置く
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := logging.WithRequestID(r.Context(), id)
+		logger := slog.Default().With("request_id", id)
+		if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+			logger = logger.With("trace_id", sc.TraceID().String())
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("http.request_id", id))
+		}
+		next.ServeHTTP(w, r.WithContext(logging.NewContext(ctx, logger)))
+	})
+}
+
+// ログやヘッダーにそのまま出せる文字だけを受け付ける
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		ok := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == ':'
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"backend/internal/logging"
+	"context"
+	"net/http"
+	"time"
+)
+
+// サーバー全体の WriteTimeout より長く応答を書き続けるルートに使う
+// d が 0 の場合は書き込みの期限をなくす（SSE など）
+func WriteTimeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var deadline time.Time
+			if d > 0 {
+				deadline = time.Now().Add(d)
+			}
+			if err := http.NewResponseController(w).SetWriteDeadline(deadline); err != nil {
+				logging.FromContext(r.Context()).Warn("Failed to extend write deadline", "path", r.URL.Path, "error", err)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// stop が閉じられたらリクエストのコンテキストをキャンセルする
+// http.Server.Shutdown は接続中のリクエストの終了を待つだけなので、
+// 終わりのないストリーム（SSE）はこれで切断し、クライアントに再接続させる
+func CancelOn(stop <-chan struct{}) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+			go func() {
+				select {
+				case <-stop:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -2,7 +2,8 @@ package middleware
 
 import (
 	"context"
-	"log"
+	"log/slog"
+	"os"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -15,7 +16,8 @@ import (
 func InitTracing(collectorURL string) func(context.Context) error {
 	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(collectorURL)))
 	if err != nil {
-		log.Fatalf("failed to create jaeger exporter: %v", err)
+		slog.Error("failed to create jaeger exporter", "error", err)
+		os.Exit(1)
 	}
 
 	res, err := resource.New(context.Background(),
@@ -29,7 +31,8 @@ func InitTracing(collectorURL string) func(context.Context) error {
 		),
 	)
 	if err != nil {
-		log.Fatalf("failed to create resource: %v", err)
+		slog.Error("failed to create resource", "error", err)
+		os.Exit(1)
 	}
 
 	tp := sdktrace.NewTracerProvider(
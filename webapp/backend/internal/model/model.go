@@ -30,6 +30,69 @@ type Order struct {
 	Value         int          `db:"value"           json:"value"`
 	CreatedAt     time.Time    `db:"created_at"      json:"created_at"`
 	ArrivedAt     sql.NullTime `db:"arrived_at"      json:"arrived_at"`
+	GroupID       *int64       `db:"group_id"        json:"group_id"`
+	ETA           *DeliveryETA `db:"-"               json:"eta,omitempty"`
+}
+
+// 注文の配送ステータス
+const (
+	StatusShipping   = "shipping"
+	StatusDelivering = "delivering"
+	StatusCompleted  = "completed"
+	StatusCancelled  = "cancelled"
+)
+
+// 注文の配送ステータスとして有効な値かどうか
+func IsValidShippedStatus(status string) bool {
+	switch status {
+	case StatusShipping, StatusDelivering, StatusCompleted, StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// 配達予定時刻の推定
+// 配達済み・キャンセル済みの注文には付かない。
+// Basis は推定に使った情報で、"backlog"（現在の配送待ちの順番）か "historical"（過去の所要時間）
+type DeliveryETA struct {
+	EstimatedArrival time.Time `json:"estimated_arrival"`
+	Earliest         time.Time `json:"earliest"`
+	Latest           time.Time `json:"latest"`
+	Basis            string    `json:"basis"`
+}
+
+const (
+	ETABasisBacklog    = "backlog"
+	ETABasisHistorical = "historical"
+)
+
+// 配達済みの注文の実績（配達予定時刻の推定と精度の検証に使う）
+type DeliverySample struct {
+	OrderID   int64     `db:"order_id"`
+	CreatedAt time.Time `db:"created_at"`
+	ArrivedAt time.Time `db:"arrived_at"`
+	Value     int       `db:"value"`
+	Weight    int       `db:"weight"`
+}
+
+// 過去の時点での配送待ちの列を求めるための注文
+type BacklogOrder struct {
+	OrderID   int64        `db:"order_id"`
+	CreatedAt time.Time    `db:"created_at"`
+	ArrivedAt sql.NullTime `db:"arrived_at"`
+	Value     int          `db:"value"`
+	Weight    int          `db:"weight"`
+}
+
+// 過去の注文に対する配達予定時刻の推定精度
+// 誤差は推定 - 実績（秒）で、正の値は遅めに見積もったことを表す
+type ETAAccuracy struct {
+	Samples           int     `json:"samples"`
+	MeanErrorSec      float64 `json:"mean_error_sec"`
+	MeanAbsErrorSec   float64 `json:"mean_abs_error_sec"`
+	MedianAbsErrorSec float64 `json:"median_abs_error_sec"`
+	P90AbsErrorSec    float64 `json:"p90_abs_error_sec"`
+	WithinRangeRate   float64 `json:"within_range_rate"`
 }
 
 type DeliveryPlan struct {
@@ -49,13 +112,15 @@ type CreateOrderRequest struct {
 }
 
 type RequestItem struct {
-	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+	ProductID int `db:"product_id" json:"product_id"`
+	Quantity  int `db:"quantity"   json:"quantity"`
 }
 
 type UpdateOrderStatusRequest struct {
 	OrderID   int64  `json:"order_id"`
 	NewStatus string `json:"new_status"`
+	// 省略した場合は既定のロボット
+	RobotID string `json:"robot_id,omitempty"`
 }
 
 type ListRequest struct {
@@ -70,4 +135,225 @@ type ListRequest struct {
 	AfterID     *int `json:"after_id,omitempty"`     // product_id の続き用
 	AfterValue  *int `json:"after_value,omitempty"`  // SortField=value のときに使う
 	AfterWeight *int `json:"after_weight,omitempty"` // SortField=weight のときに使う
+
+	// 注文履歴の絞り込み用（未指定の項目は絞り込まない）
+	// 日時の範囲は From 以上 To 未満
+	ShippedStatuses []string   `json:"shipped_statuses,omitempty"`
+	ProductID       *int       `json:"product_id,omitempty"`
+	CreatedFrom     *time.Time `json:"created_from,omitempty"`
+	CreatedTo       *time.Time `json:"created_to,omitempty"`
+	ArrivedFrom     *time.Time `json:"arrived_from,omitempty"`
+	ArrivedTo       *time.Time `json:"arrived_to,omitempty"`
+}
+
+// Idempotency-Key 付きの注文作成リクエストの記録
+type IdempotencyKey struct {
+	UserID      int            `db:"user_id"`
+	Key         string         `db:"idempotency_key"`
+	RequestHash string         `db:"request_hash"`
+	Status      string         `db:"status"`
+	StatusCode  sql.NullInt64  `db:"status_code"`
+	GroupID     sql.NullInt64  `db:"group_id"`
+	OrderIDs    sql.NullString `db:"order_ids"`
+	CreatedAt   time.Time      `db:"created_at"`
+	ExpiresAt   time.Time      `db:"expires_at"`
+}
+
+const (
+	IdempotencyStatusInProgress = "in_progress"
+	IdempotencyStatusCompleted  = "completed"
+)
+
+// 注文ステータスの変更履歴
+// Actor は "user:<user_id>" や "robot:<robot_id>" の形式で変更した主体を表す
+type OrderStatusHistory struct {
+	OrderID   int64     `db:"order_id"   json:"-"`
+	OldStatus *string   `db:"old_status" json:"old_status"`
+	NewStatus string    `db:"new_status" json:"new_status"`
+	Actor     string    `db:"actor"      json:"actor"`
+	ChangedAt time.Time `db:"changed_at" json:"changed_at"`
+}
+
+// 注文詳細（ステータス変更履歴付き）
+type OrderDetail struct {
+	Order
+	History []OrderStatusHistory `json:"history"`
+}
+
+// カート内の商品（現在の商品の価格・重さ付き）
+type CartItem struct {
+	ProductID int    `db:"product_id" json:"product_id"`
+	Name      string `db:"name"       json:"name"`
+	Value     int    `db:"value"      json:"value"`
+	Weight    int    `db:"weight"     json:"weight"`
+	Image     string `db:"image"      json:"image"`
+	Quantity  int    `db:"quantity"   json:"quantity"`
+}
+
+type Cart struct {
+	Items         []CartItem `json:"items"`
+	TotalQuantity int        `json:"total_quantity"`
+	TotalValue    int        `json:"total_value"`
+	TotalWeight   int        `json:"total_weight"`
+}
+
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+// 1回の購入でまとめて作成された注文のグループ
+type OrderGroup struct {
+	GroupID       int64              `db:"group_id"   json:"group_id"`
+	CreatedAt     time.Time          `db:"created_at" json:"created_at"`
+	Items         []OrderGroupItem   `db:"-"          json:"items"`
+	TotalQuantity int                `db:"-"          json:"total_quantity"`
+	Progress      OrderGroupProgress `db:"-"          json:"progress"`
+}
+
+// 注文グループ内の商品ごとの数量とステータス別の件数
+type OrderGroupItem struct {
+	GroupID     int64  `db:"group_id"     json:"-"`
+	ProductID   int    `db:"product_id"   json:"product_id"`
+	ProductName string `db:"product_name" json:"product_name"`
+	Quantity    int    `db:"quantity"     json:"quantity"`
+	OrderGroupProgress
+}
+
+// ステータス別の注文件数
+type OrderGroupProgress struct {
+	Shipping   int `db:"shipping"   json:"shipping"`
+	Delivering int `db:"delivering" json:"delivering"`
+	Completed  int `db:"completed"  json:"completed"`
+	Cancelled  int `db:"cancelled"  json:"cancelled"`
+}
+
+func (p *OrderGroupProgress) Add(other OrderGroupProgress) {
+	p.Shipping += other.Shipping
+	p.Delivering += other.Delivering
+	p.Completed += other.Completed
+	p.Cancelled += other.Cancelled
+}
+
+// Webhook のイベント種別
+const (
+	WebhookEventOrderCreated   = "order.created"
+	WebhookEventOrderClaimed   = "order.claimed"
+	WebhookEventOrderDelivered = "order.delivered"
+)
+
+// Webhook の配送状況
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook の通知先
+type WebhookEndpoint struct {
+	EndpointID int64     `db:"endpoint_id" json:"endpoint_id"`
+	URL        string    `db:"url"         json:"url"`
+	Secret     string    `db:"secret"      json:"secret,omitempty"`
+	EventTypes string    `db:"event_types" json:"event_types"`
+	Active     bool      `db:"active"      json:"active"`
+	CreatedAt  time.Time `db:"created_at"  json:"created_at"`
+}
+
+type CreateWebhookEndpointRequest struct {
+	URL        string   `json:"url"`
+	Secret     string   `json:"secret"`
+	EventTypes []string `json:"event_types"`
+}
+
+// 通知先ごとの配送状況
+// EventID はもとになったドメインイベントの ID
+type WebhookDelivery struct {
+	DeliveryID     int64     `db:"delivery_id"      json:"delivery_id"`
+	EventID        int64     `db:"event_id"         json:"event_id"`
+	EndpointID     int64     `db:"endpoint_id"      json:"endpoint_id"`
+	EventType      string    `db:"event_type"       json:"event_type"`
+	Status         string    `db:"status"           json:"status"`
+	Attempts       int       `db:"attempts"         json:"attempts"`
+	NextAttemptAt  time.Time `db:"next_attempt_at"  json:"next_attempt_at"`
+	LastStatusCode *int      `db:"last_status_code" json:"last_status_code"`
+	LastError      *string   `db:"last_error"       json:"last_error"`
+	CreatedAt      time.Time `db:"created_at"       json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"       json:"updated_at"`
+}
+
+// 配送処理が送信に使う、通知先と送信する内容を含む配送
+type WebhookDeliveryJob struct {
+	WebhookDelivery
+	URL       string    `db:"url"`
+	Secret    string    `db:"secret"`
+	Payload   []byte    `db:"payload"`
+	EventTime time.Time `db:"event_created_at"`
+}
+
+// 配送試行のログ
+type WebhookDeliveryAttempt struct {
+	DeliveryID  int64     `db:"delivery_id"  json:"delivery_id"`
+	Attempt     int       `db:"attempt"      json:"attempt"`
+	StatusCode  *int      `db:"status_code"  json:"status_code"`
+	Error       *string   `db:"error"        json:"error"`
+	DurationMS  int64     `db:"duration_ms"  json:"duration_ms"`
+	AttemptedAt time.Time `db:"attempted_at" json:"attempted_at"`
+}
+
+// ドメインイベントの集約の種類
+const (
+	AggregateOrder      = "order"
+	AggregateOrderGroup = "order_group"
+)
+
+// 注文のドメインイベント種別
+const (
+	DomainEventOrderCreated       = "order.created"
+	DomainEventOrderStatusChanged = "order.status_changed"
+	DomainEventOrderGroupCreated  = "order_group.created"
+)
+
+// 状態変更と同じトランザクションで記録するドメインイベント
+type DomainEvent struct {
+	EventID       int64     `db:"event_id"`
+	AggregateType string    `db:"aggregate_type"`
+	AggregateID   int64     `db:"aggregate_id"`
+	EventType     string    `db:"event_type"`
+	Payload       []byte    `db:"payload"`
+	OccurredAt    time.Time `db:"occurred_at"`
+}
+
+// おすすめ商品
+// Reason は "co_purchase"（一緒に購入されている）か "popular"（人気商品）
+type RecommendedProduct struct {
+	Product
+	Score  float64 `db:"score"  json:"score"`
+	Reason string  `db:"-"      json:"reason"`
+}
+
+const (
+	RecommendationReasonCoPurchase = "co_purchase"
+	RecommendationReasonPopular    = "popular"
+)
+
+// 商品間の類似度
+type ProductSimilarity struct {
+	ProductID        int     `db:"product_id"`
+	RelatedProductID int     `db:"related_product_id"`
+	Score            float64 `db:"score"`
+	CoPurchases      int     `db:"co_purchases"`
+}
+
+// 商品の人気度
+type ProductPopularity struct {
+	ProductID  int     `db:"product_id"`
+	BuyerCount int     `db:"buyer_count"`
+	Score      float64 `db:"score"`
+}
+
+// 内容のハッシュで管理する画像
+type ImageObject struct {
+	Hash        string    `db:"hash"`
+	Size        int64     `db:"size"`
+	ContentType string    `db:"content_type"`
+	CreatedAt   time.Time `db:"created_at"`
 }
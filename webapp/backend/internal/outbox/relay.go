@@ -0,0 +1,206 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+const (
+	defaultPollInterval = 1 * time.Second
+	defaultBatchSize    = 500
+	// 配信に失敗した配信先を再試行するまでの待ち時間の上限
+	defaultMaxBackoff = 1 * time.Minute
+)
+
+// domain_events のイベントを配信先へ送る
+// 配信先ごとに送信済みの位置（domain_event_offsets）を持ち、ID 順に送る。
+// 配信先への送信が成功してから位置を進めるため、停止や失敗の後は同じイベントが再送される
+type Relay struct {
+	store        *repository.Store
+	sinks        []Sink
+	PollInterval time.Duration
+	BatchSize    int
+	MaxBackoff   time.Duration
+}
+
+func NewRelay(store *repository.Store, sinks ...Sink) *Relay {
+	return &Relay{
+		store:        store,
+		sinks:        sinks,
+		PollInterval: defaultPollInterval,
+		BatchSize:    defaultBatchSize,
+		MaxBackoff:   defaultMaxBackoff,
+	}
+}
+
+// ctx がキャンセルされるまで配信を続ける
+// 配信先ごとに独立して動くため、遅い配信先が他の配信先を止めることはない
+func (r *Relay) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, sink := range r.sinks {
+		wg.Add(1)
+		go func(sink Sink) {
+			defer wg.Done()
+			r.runSink(ctx, sink)
+		}(sink)
+	}
+	wg.Wait()
+}
+
+func (r *Relay) runSink(ctx context.Context, sink Sink) {
+	offset, err := r.loadOffset(ctx, sink)
+	if err != nil {
+		return
+	}
+
+	wait := r.PollInterval
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		next, more, err := r.relayBatch(ctx, sink, offset)
+		if err != nil {
+			failures++
+			wait = r.backoff(failures)
+			slog.Warn("Failed to relay domain events", "sink", sink.Name(), "retry_in", wait, "error", err)
+			continue
+		}
+		failures = 0
+		offset = next
+		// 溜まっている間は待たずに続けて送る
+		if more {
+			wait = 0
+		} else {
+			wait = r.PollInterval
+		}
+	}
+}
+
+// 送信済みの位置を取得する。DB に接続できない間は再試行する
+func (r *Relay) loadOffset(ctx context.Context, sink Sink) (int64, error) {
+	for failures := 1; ; failures++ {
+		offset, err := r.store.DomainEventRepo.GetOffset(ctx, sink.Name())
+		if err == nil {
+			return offset, nil
+		}
+		slog.Warn("Failed to load domain event offset", "sink", sink.Name(), "error", err)
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(r.backoff(failures)):
+		}
+	}
+}
+
+// offset より後のイベントを1バッチ送り、新しい位置を返す
+// more はまだ送るイベントが残っている可能性がある場合に true
+func (r *Relay) relayBatch(ctx context.Context, sink Sink, offset int64) (int64, bool, error) {
+	evs, err := r.store.DomainEventRepo.ListAfter(ctx, offset, r.BatchSize)
+	if err != nil {
+		return offset, false, err
+	}
+	full := len(evs) == r.BatchSize
+
+	evs, next, err := r.contiguous(ctx, offset, evs)
+	if err != nil {
+		return offset, false, err
+	}
+	if len(evs) > 0 {
+		if err := sink.Publish(ctx, evs); err != nil {
+			return offset, false, err
+		}
+	}
+	if next == offset {
+		return offset, false, nil
+	}
+	if err := r.store.DomainEventRepo.SaveOffset(ctx, sink.Name(), next); err != nil {
+		// 送信済みの位置はメモリ上で進めておき、次回の保存に任せる（再起動時は再送される）
+		slog.Warn("Failed to save domain event offset", "sink", sink.Name(), "error", err)
+	}
+	return next, full, nil
+}
+
+// ID が連続している先頭部分だけを返す
+// イベント ID は採番順にコミットされるとは限らないため、欠番より後のイベントを先に送ると
+// 後からコミットされたイベントを読み飛ばしてしまう。
+// 欠番が書き込み中であればそこで止め、ロールバックされたものであれば読み飛ばす
+func (r *Relay) contiguous(ctx context.Context, offset int64, evs []model.DomainEvent) ([]model.DomainEvent, int64, error) {
+	expected := offset + 1
+	for i, ev := range evs {
+		if ev.EventID != expected {
+			pending, committed, err := r.store.DomainEventRepo.ProbeGap(ctx, expected, ev.EventID-1)
+			if err != nil {
+				return nil, offset, err
+			}
+			if pending || committed {
+				// 書き込み中、または取得後にコミットされた。次回の取得に任せる
+				return evs[:i], expected - 1, nil
+			}
+		}
+		expected = ev.EventID + 1
+	}
+	return evs, expected - 1, nil
+}
+
+// 保持期間を過ぎ、すべての配信先が送信済みのイベントを定期的に削除する
+func (r *Relay) RunPurger(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := r.purge(ctx, time.Now().Add(-retention))
+			if err != nil {
+				slog.Error("Failed to purge domain events", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.Info("Purged domain events", "events", n)
+			}
+		}
+	}
+}
+
+// 配信先のうち最も遅れている位置までのイベントを削除する
+// 位置を保存していない配信先があれば何も削除しない
+func (r *Relay) purge(ctx context.Context, before time.Time) (int64, error) {
+	if len(r.sinks) == 0 {
+		return 0, nil
+	}
+	var oldest int64 = -1
+	for _, sink := range r.sinks {
+		offset, err := r.store.DomainEventRepo.GetOffset(ctx, sink.Name())
+		if err != nil {
+			return 0, err
+		}
+		if oldest < 0 || offset < oldest {
+			oldest = offset
+		}
+	}
+	if oldest == 0 {
+		return 0, nil
+	}
+	return r.store.DomainEventRepo.DeleteRelayed(ctx, oldest, before)
+}
+
+func (r *Relay) backoff(failures int) time.Duration {
+	wait := r.PollInterval
+	for i := 0; i < failures && wait < r.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > r.MaxBackoff {
+		wait = r.MaxBackoff
+	}
+	return wait
+}
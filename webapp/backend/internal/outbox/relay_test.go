@@ -0,0 +1,131 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"backend/internal/dbtest"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+type namedSink string
+
+func (s namedSink) Name() string { return string(s) }
+
+func (s namedSink) Publish(context.Context, []model.DomainEvent) error { return nil }
+
+func TestRelayPurgeKeepsUnrelayedEvents(t *testing.T) {
+	db := dbtest.Open(t)
+	store := repository.NewStore(db)
+	ctx := context.Background()
+
+	old := time.Now().Add(-48 * time.Hour)
+	var evs []model.DomainEvent
+	for i := 1; i <= 5; i++ {
+		evs = append(evs, model.DomainEvent{
+			AggregateType: model.AggregateOrder,
+			AggregateID:   int64(i),
+			EventType:     model.DomainEventOrderCreated,
+			Payload:       []byte(`{}`),
+			OccurredAt:    old,
+		})
+	}
+	evs[4].OccurredAt = time.Now()
+	if err := store.DomainEventRepo.Append(ctx, evs); err != nil {
+		t.Fatal(err)
+	}
+	relay := NewRelay(store, namedSink("a"), namedSink("b"))
+	remaining := func() []int64 {
+		var ids []int64
+		if err := db.Select(&ids, "SELECT event_id FROM domain_events ORDER BY event_id"); err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+
+	// b がまだ位置を保存していない
+	if err := store.DomainEventRepo.SaveOffset(ctx, "a", 5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := relay.purge(ctx, time.Now().Add(-24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if ids := remaining(); len(ids) != 5 {
+		t.Fatalf("remaining = %v, want all events while a sink has no offset", ids)
+	}
+
+	if err := store.DomainEventRepo.SaveOffset(ctx, "b", 3); err != nil {
+		t.Fatal(err)
+	}
+	n, err := relay.purge(ctx, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b が送信済みの 1〜3 だけを削除し、保持期間内の 5 は残す
+	if ids := remaining(); n != 3 || len(ids) != 2 || ids[0] != 4 || ids[1] != 5 {
+		t.Fatalf("purged %d, remaining = %v, want 3 purged and [4 5] left", n, ids)
+	}
+}
+
+func TestRelayBus(t *testing.T) {
+	db := dbtest.Open(t)
+	store := repository.NewStore(db)
+	ctx := context.Background()
+
+	var evs []model.DomainEvent
+	for i := 1; i <= 3; i++ {
+		evs = append(evs, model.DomainEvent{
+			AggregateType: model.AggregateOrder,
+			AggregateID:   int64(i),
+			EventType:     model.DomainEventOrderCreated,
+			Payload:       []byte(`{}`),
+			OccurredAt:    time.Now(),
+		})
+	}
+	if err := store.DomainEventRepo.Append(ctx, evs); err != nil {
+		t.Fatal(err)
+	}
+
+	bus := NewBus()
+	var got []int64
+	failAt := int64(2)
+	bus.Subscribe(func(_ context.Context, ev model.DomainEvent) error {
+		if ev.EventID == failAt {
+			return errors.New("handler failed")
+		}
+		got = append(got, ev.EventID)
+		return nil
+	})
+	var second []int64
+	bus.Subscribe(func(_ context.Context, ev model.DomainEvent) error {
+		second = append(second, ev.EventID)
+		return nil
+	})
+	relay := NewRelay(store, bus)
+
+	// 処理が失敗した場合は位置を進めず、バッチ全体を再送する
+	offset, _, err := relay.relayBatch(ctx, bus, 0)
+	if err == nil || offset != 0 {
+		t.Fatalf("offset = %d, err = %v, want 0 and an error", offset, err)
+	}
+	got, second, failAt = nil, nil, 0
+	offset, _, err = relay.relayBatch(ctx, bus, offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset != 3 {
+		t.Errorf("offset = %d, want 3", offset)
+	}
+	// 登録したすべての処理に ID 順に届く
+	for _, ids := range [][]int64{got, second} {
+		if len(ids) != 3 || ids[0] != 1 || ids[1] != 2 || ids[2] != 3 {
+			t.Errorf("handled %v, want [1 2 3]", ids)
+		}
+	}
+	if saved, err := relay.loadOffset(ctx, bus); err != nil || saved != 3 {
+		t.Errorf("saved offset = %d, %v, want 3", saved, err)
+	}
+}
@@ -0,0 +1,175 @@
+package outbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/internal/model"
+)
+
+// イベントの配信先
+// 配信先ごとに送信済みの位置を記録し、失敗したバッチは同じ位置から再送する（at-least-once）。
+// 同じイベントが重複して届くことがあるため、受け取る側は EventID で重複を除くこと
+type Sink interface {
+	// 送信済みの位置を記録する際の名前。変更すると最初から再送される
+	Name() string
+	// ID 順のイベントをまとめて送る。エラーを返した場合はバッチ全体を再送する
+	Publish(ctx context.Context, evs []model.DomainEvent) error
+}
+
+// 配信先に送るイベントの形式
+type Envelope struct {
+	ID            int64           `json:"id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   int64           `json:"aggregate_id"`
+	Type          string          `json:"type"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Data          json.RawMessage `json:"data"`
+}
+
+func NewEnvelope(ev model.DomainEvent) Envelope {
+	return Envelope{
+		ID:            ev.EventID,
+		AggregateType: ev.AggregateType,
+		AggregateID:   ev.AggregateID,
+		Type:          ev.EventType,
+		OccurredAt:    ev.OccurredAt,
+		Data:          json.RawMessage(ev.Payload),
+	}
+}
+
+// ---- プロセス内のバス ----
+
+// イベントを受け取る処理
+type Handler func(ctx context.Context, ev model.DomainEvent) error
+
+// 同じプロセス内の処理へイベントを配る配信先
+// 登録された処理を順に同期的に呼び出し、いずれかが失敗するとバッチ全体を再送する
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Name() string {
+	return "bus"
+}
+
+// イベントを受け取る処理を登録する
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+func (b *Bus) Publish(ctx context.Context, evs []model.DomainEvent) error {
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	for _, ev := range evs {
+		for _, h := range handlers {
+			if err := h(ctx, ev); err != nil {
+				return fmt.Errorf("handler failed for event %d: %w", ev.EventID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ---- JSON Lines ファイル ----
+
+// イベントを1行1件の JSON でファイルに追記する配信先
+type FileSink struct {
+	path string
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (s *FileSink) Name() string {
+	return "file:" + s.path
+}
+
+func (s *FileSink) Publish(ctx context.Context, evs []model.DomainEvent) error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+	enc := json.NewEncoder(bw)
+	for _, ev := range evs {
+		if err := enc.Encode(NewEnvelope(ev)); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	// 位置を進める前にディスクへ書き出しておく
+	return f.Sync()
+}
+
+// ---- HTTP ----
+
+// イベントを {"events": [...]} の形で POST する配信先
+// 2xx 以外の応答は失敗として再送する
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+// client が nil の場合はタイムアウト 10 秒のクライアントを使う
+func NewHTTPSink(url string, client *http.Client) *HTTPSink {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &HTTPSink{url: url, client: client}
+}
+
+func (s *HTTPSink) Name() string {
+	return "http:" + s.url
+}
+
+func (s *HTTPSink) Publish(ctx context.Context, evs []model.DomainEvent) error {
+	envelopes := make([]Envelope, len(evs))
+	for i, ev := range evs {
+		envelopes[i] = NewEnvelope(ev)
+	}
+	body, err := json.Marshal(map[string]interface{}{"events": envelopes})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
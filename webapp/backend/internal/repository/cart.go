@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"backend/internal/model"
+)
+
+type CartRepository struct {
+	db DBTX
+}
+
+func NewCartRepository(db DBTX) *CartRepository {
+	return &CartRepository{db: db}
+}
+
+// カート内の商品を価格・重さ付きで取得
+func (r *CartRepository) List(ctx context.Context, userID int) ([]model.CartItem, error) {
+	items := []model.CartItem{}
+	query := `
+		SELECT
+			c.product_id,
+			p.name,
+			p.value,
+			p.weight,
+			p.image,
+			c.quantity
+		FROM cart_items c
+		JOIN products p ON c.product_id = p.product_id
+		WHERE c.user_id = ?
+		ORDER BY c.created_at ASC, c.product_id ASC`
+	if err := r.db.SelectContext(ctx, &items, query, userID); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// 購入手続き用にカートの中身を行ロック付きで取得
+// トランザクション内で呼び出すこと
+func (r *CartRepository) ListForCheckout(ctx context.Context, userID int) ([]model.RequestItem, error) {
+	items := []model.RequestItem{}
+	query := `
+		SELECT product_id, quantity
+		FROM cart_items
+		WHERE user_id = ?
+		ORDER BY created_at ASC, product_id ASC
+		FOR UPDATE`
+	if err := r.db.SelectContext(ctx, &items, query, userID); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// ユーザーの行をロックし、同じユーザーのカートを変更するトランザクションを直列にする
+// トランザクション内で、カートを読み書きする前に呼び出すこと
+func (r *CartRepository) LockUser(ctx context.Context, userID int) error {
+	var id int
+	err := r.db.GetContext(ctx, &id, "SELECT user_id FROM users WHERE user_id = ? FOR UPDATE", userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+// カート内の商品の数量を行ロック付きで取得
+// カートに入っていない場合は 0 を返す
+func (r *CartRepository) GetQuantityForUpdate(ctx context.Context, userID, productID int) (int, error) {
+	var quantity int
+	query := "SELECT quantity FROM cart_items WHERE user_id = ? AND product_id = ? FOR UPDATE"
+	if err := r.db.GetContext(ctx, &quantity, query, userID, productID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return quantity, nil
+}
+
+// カート内の商品の種類数
+func (r *CartRepository) CountItems(ctx context.Context, userID int) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, "SELECT COUNT(*) FROM cart_items WHERE user_id = ?", userID)
+	return n, err
+}
+
+// 商品の数量を設定する（カートになければ追加する）
+func (r *CartRepository) Upsert(ctx context.Context, userID, productID, quantity int) error {
+	now := time.Now()
+	query := `
+		INSERT INTO cart_items (user_id, product_id, quantity, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE quantity = VALUES(quantity), updated_at = VALUES(updated_at)`
+	_, err := r.db.ExecContext(ctx, query, userID, productID, quantity, now, now)
+	return err
+}
+
+// 商品の数量を加算し（カートになければ追加する）、加算後の数量を返す
+func (r *CartRepository) AddQuantity(ctx context.Context, userID, productID, delta int) (int, error) {
+	now := time.Now()
+	query := `
+		INSERT INTO cart_items (user_id, product_id, quantity, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE quantity = quantity + VALUES(quantity), updated_at = VALUES(updated_at)`
+	if _, err := r.db.ExecContext(ctx, query, userID, productID, delta, now, now); err != nil {
+		return 0, err
+	}
+	var quantity int
+	err := r.db.GetContext(ctx, &quantity, "SELECT quantity FROM cart_items WHERE user_id = ? AND product_id = ? FOR UPDATE", userID, productID)
+	return quantity, err
+}
+
+// カートから商品を削除し、削除できたかどうかを返す
+func (r *CartRepository) Remove(ctx context.Context, userID, productID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM cart_items WHERE user_id = ? AND product_id = ?", userID, productID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// カートを空にする
+func (r *CartRepository) Clear(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM cart_items WHERE user_id = ?", userID)
+	return err
+}
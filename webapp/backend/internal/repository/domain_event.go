@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// NOWAIT の行ロック取得がロック待ちで失敗した（ER_LOCK_NOWAIT）
+const mysqlErrLockNowait = 3572
+
+type DomainEventRepository struct {
+	db DBTX
+}
+
+func NewDomainEventRepository(db DBTX) *DomainEventRepository {
+	return &DomainEventRepository{db: db}
+}
+
+// イベントを追加する
+// 状態変更と同じトランザクション内で呼び出すこと
+func (r *DomainEventRepository) Append(ctx context.Context, evs []model.DomainEvent) error {
+	const batchSize = 1000
+	for start := 0; start < len(evs); start += batchSize {
+		end := start + batchSize
+		if end > len(evs) {
+			end = len(evs)
+		}
+
+		query := "INSERT INTO domain_events (aggregate_type, aggregate_id, event_type, payload, occurred_at) VALUES "
+		var args []interface{}
+		var placeholders []string
+		for _, ev := range evs[start:end] {
+			placeholders = append(placeholders, "(?, ?, ?, ?, ?)")
+			// []byte のままだと binary 文字セットとして送られ JSON 列に入らないため文字列で渡す
+			args = append(args, ev.AggregateType, ev.AggregateID, ev.EventType, string(ev.Payload), ev.OccurredAt)
+		}
+		query += strings.Join(placeholders, ",")
+
+		if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to append domain events: %w", err)
+		}
+	}
+	return nil
+}
+
+// afterID より後のイベントを ID 順に取得
+func (r *DomainEventRepository) ListAfter(ctx context.Context, afterID int64, limit int) ([]model.DomainEvent, error) {
+	evs := []model.DomainEvent{}
+	query := `
+		SELECT event_id, aggregate_type, aggregate_id, event_type, payload, occurred_at
+		FROM domain_events
+		WHERE event_id > ?
+		ORDER BY event_id ASC
+		LIMIT ?`
+	if err := r.db.SelectContext(ctx, &evs, query, afterID, limit); err != nil {
+		return nil, err
+	}
+	return evs, nil
+}
+
+// ID の欠番 [fromID, toID] の状態を調べる
+// 未コミットのトランザクションが書き込み中の場合は pending、
+// コミット済みのイベントが見つかった場合は committed が true になる。
+// どちらも false の場合、欠番はロールバックされたもので今後も現れない
+func (r *DomainEventRepository) ProbeGap(ctx context.Context, fromID, toID int64) (pending, committed bool, err error) {
+	var ids []int64
+	query := "SELECT event_id FROM domain_events WHERE event_id BETWEEN ? AND ? FOR SHARE NOWAIT"
+	err = r.db.SelectContext(ctx, &ids, query, fromID, toID)
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlErrLockNowait {
+			return true, false, nil
+		}
+		return false, false, err
+	}
+	return false, len(ids) > 0, nil
+}
+
+// 配信先の送信済みの位置を取得（未登録の場合は 0）
+func (r *DomainEventRepository) GetOffset(ctx context.Context, consumer string) (int64, error) {
+	var offsets []int64
+	err := r.db.SelectContext(ctx, &offsets, "SELECT last_event_id FROM domain_event_offsets WHERE consumer = ?", consumer)
+	if err != nil || len(offsets) == 0 {
+		return 0, err
+	}
+	return offsets[0], nil
+}
+
+// 配信先の送信済みの位置を保存する（後退はさせない）
+func (r *DomainEventRepository) SaveOffset(ctx context.Context, consumer string, lastEventID int64) error {
+	query := `
+		INSERT INTO domain_event_offsets (consumer, last_event_id, updated_at)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			last_event_id = GREATEST(last_event_id, VALUES(last_event_id)),
+			updated_at = VALUES(updated_at)`
+	_, err := r.db.ExecContext(ctx, query, consumer, lastEventID, time.Now())
+	return err
+}
+
+// 全配信先が送信済みの lastEventID 以下のイベントのうち、before より前に発生したものを削除する
+func (r *DomainEventRepository) DeleteRelayed(ctx context.Context, lastEventID int64, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM domain_events WHERE event_id <= ? AND occurred_at < ?", lastEventID, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 同じ Idempotency-Key が既に登録されている
+var ErrDuplicateIdempotencyKey = errors.New("duplicate idempotency key")
+
+type IdempotencyRepository struct {
+	db DBTX
+}
+
+func NewIdempotencyRepository(db DBTX) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+// 処理中としてキーを登録する
+// 既に有効なキーが存在する場合は ErrDuplicateIdempotencyKey を返す
+func (r *IdempotencyRepository) Reserve(ctx context.Context, userID int, key, requestHash string, ttl time.Duration) error {
+	// 保持期間を過ぎたキーは再利用できるよう先に消しておく
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE user_id = ? AND idempotency_key = ? AND expires_at <= ?",
+		userID, key, time.Now())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	query := `
+		INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash, status, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	_, err = r.db.ExecContext(ctx, query, userID, key, requestHash, model.IdempotencyStatusInProgress, now, now.Add(ttl))
+	if err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+			return ErrDuplicateIdempotencyKey
+		}
+		return err
+	}
+	return nil
+}
+
+// キーの記録を取得する
+func (r *IdempotencyRepository) Find(ctx context.Context, userID int, key string) (*model.IdempotencyKey, error) {
+	var rec model.IdempotencyKey
+	query := `
+		SELECT user_id, idempotency_key, request_hash, status, status_code, group_id, order_ids, created_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = ? AND idempotency_key = ?`
+	if err := r.db.GetContext(ctx, &rec, query, userID, key); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// 処理結果を保存して完了にする
+func (r *IdempotencyRepository) Complete(ctx context.Context, userID int, key string, statusCode int, groupID int64, orderIDsJSON string, retention time.Duration) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status = ?, status_code = ?, group_id = ?, order_ids = ?, expires_at = ?
+		WHERE user_id = ? AND idempotency_key = ?`
+	_, err := r.db.ExecContext(ctx, query,
+		model.IdempotencyStatusCompleted, statusCode, sql.NullInt64{Int64: groupID, Valid: groupID != 0}, orderIDsJSON, time.Now().Add(retention),
+		userID, key)
+	return err
+}
+
+// 処理に失敗したキーを解放し、クライアントが再試行できるようにする
+func (r *IdempotencyRepository) Release(ctx context.Context, userID int, key string) error {
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE user_id = ? AND idempotency_key = ? AND status = ?",
+		userID, key, model.IdempotencyStatusInProgress)
+	return err
+}
+
+// 保持期間を過ぎたキーを削除し、削除件数を返す
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= ?", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"backend/internal/model"
+)
+
+type ImageRepository struct {
+	db DBTX
+}
+
+func NewImageRepository(db DBTX) *ImageRepository {
+	return &ImageRepository{db: db}
+}
+
+// パスに対応する画像を取得（登録されていない場合は nil）
+func (r *ImageRepository) FindByPath(ctx context.Context, path string) (*model.ImageObject, error) {
+	var obj model.ImageObject
+	query := `
+		SELECT o.hash, o.size, o.content_type, o.created_at
+		FROM image_paths p
+		JOIN image_objects o ON p.hash = o.hash
+		WHERE p.path = ?`
+	if err := r.db.GetContext(ctx, &obj, query, path); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &obj, nil
+}
+
+// 画像を登録する（登録済みの場合は何もしない）
+func (r *ImageRepository) SaveObject(ctx context.Context, obj model.ImageObject) error {
+	query := `
+		INSERT IGNORE INTO image_objects (hash, size, content_type, created_at)
+		VALUES (?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, obj.Hash, obj.Size, obj.ContentType, obj.CreatedAt)
+	return err
+}
+
+// パスを画像に対応付ける（既に対応付けられている場合は付け替える）
+func (r *ImageRepository) SavePath(ctx context.Context, path, hash string) error {
+	query := `
+		INSERT INTO image_paths (path, hash, updated_at)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE hash = VALUES(hash), updated_at = VALUES(updated_at)`
+	_, err := r.db.ExecContext(ctx, query, path, hash, time.Now())
+	return err
+}
@@ -5,33 +5,79 @@ package repository
 import (
 	"backend/internal/model"
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
+// 注文の状態を変更するメソッドは、変更と同じ db でドメインイベントを記録する。
+// 変更とイベントを一緒にコミットするため、Store.ExecTx のトランザクション内で呼び出すこと
 type OrderRepository struct {
-	db DBTX
+	db     DBTX
+	idSeq  *OrderIDSequence
+	events *DomainEventRepository
 }
 
 func NewOrderRepository(db DBTX) *OrderRepository {
-	return &OrderRepository{db: db}
+	return &OrderRepository{db: db, idSeq: NewOrderIDSequence(db), events: NewDomainEventRepository(db)}
 }
 
+// 1回の INSERT 文で挿入する最大行数
+const orderInsertBatchSize = 1000
+
 // CreateOrders は複数の注文を一括で作成する（バルクインサート）
-func (r *OrderRepository) CreateOrders(ctx context.Context, userID int, productIDs []int) ([]string, error) {
+// 行数が多い場合は orderInsertBatchSize ごとに分割して INSERT する。
+// 分割した INSERT を1つの注文としてまとめるため、トランザクション内で呼び出すこと。
+// 注文IDは order_id_sequence で先に確保し、明示して挿入する
+func (r *OrderRepository) CreateOrders(ctx context.Context, userID int, groupID int64, productIDs []int) ([]string, error) {
 	if len(productIDs) == 0 {
 		return []string{}, nil
 	}
 
-	query := "INSERT INTO orders (user_id, product_id, shipped_status, created_at) VALUES "
+	firstID, err := r.idSeq.Reserve(ctx, len(productIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	insertedIDs := make([]string, 0, len(productIDs))
+	for start := 0; start < len(productIDs); start += orderInsertBatchSize {
+		end := start + orderInsertBatchSize
+		if end > len(productIDs) {
+			end = len(productIDs)
+		}
+		ids, err := r.insertOrderBatch(ctx, userID, groupID, firstID+int64(start), productIDs[start:end])
+		if err != nil {
+			return nil, err
+		}
+		insertedIDs = append(insertedIDs, ids...)
+	}
+	if groupID != 0 {
+		if err := r.appendGroupCreated(ctx, userID, groupID, firstID, len(productIDs)); err != nil {
+			return nil, err
+		}
+	}
+	return insertedIDs, nil
+}
+
+// firstID から連番の注文IDで挿入する
+// groupID が 0 の場合は注文グループに属さない注文として挿入する
+func (r *OrderRepository) insertOrderBatch(ctx context.Context, userID int, groupID int64, firstID int64, productIDs []int) ([]string, error) {
+	query := "INSERT INTO orders (order_id, user_id, product_id, group_id, shipped_status, created_at) VALUES "
 	var args []interface{}
 	var placeholders []string
+	group := sql.NullInt64{Int64: groupID, Valid: groupID != 0}
 
-	for _, pID := range productIDs {
-		placeholders = append(placeholders, "(?, ?, 'shipping', NOW())")
-		args = append(args, userID, pID)
+	insertedIDs := make([]string, len(productIDs))
+	for i, pID := range productIDs {
+		orderID := firstID + int64(i)
+		placeholders = append(placeholders, "(?, ?, ?, ?, 'shipping', NOW())")
+		args = append(args, orderID, userID, pID, group)
+		insertedIDs[i] = strconv.FormatInt(orderID, 10)
 	}
 
 	query += strings.Join(placeholders, ",")
@@ -40,38 +86,53 @@ func (r *OrderRepository) CreateOrders(ctx context.Context, userID int, productI
 	if err != nil {
 		return nil, fmt.Errorf("failed to bulk insert orders: %w", err)
 	}
-
-	lastID, err := result.LastInsertId()
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return nil, err
 	}
-	rowsAffected := int64(len(productIDs))
+	if rowsAffected != int64(len(productIDs)) {
+		return nil, fmt.Errorf("bulk insert orders: expected %d rows, got %d", len(productIDs), rowsAffected)
+	}
 
-	insertedIDs := make([]string, rowsAffected)
-	for i := 0; i < int(rowsAffected); i++ {
-		insertedIDs[i] = fmt.Sprintf("%d", int(lastID)-int(rowsAffected)+1+i)
+	now := time.Now()
+	evs := make([]model.DomainEvent, len(productIDs))
+	for i, pID := range productIDs {
+		orderID := firstID + int64(i)
+		payload, err := json.Marshal(orderCreatedEvent{
+			OrderID:       orderID,
+			UserID:        userID,
+			ProductID:     pID,
+			GroupID:       groupID,
+			ShippedStatus: model.StatusShipping,
+		})
+		if err != nil {
+			return nil, err
+		}
+		evs[i] = orderEvent(orderID, model.DomainEventOrderCreated, payload, now)
+	}
+	if err := r.events.Append(ctx, evs); err != nil {
+		return nil, err
 	}
 	return insertedIDs, nil
 }
 
-
 // 注文を作成し、生成された注文IDを返す
 func (r *OrderRepository) Create(ctx context.Context, order *model.Order) (string, error) {
-	query := `INSERT INTO orders (user_id, product_id, shipped_status, created_at) VALUES (?, ?, 'shipping', NOW())`
-	result, err := r.db.ExecContext(ctx, query, order.UserID, order.ProductID)
-	if err != nil {
-		return "", err
+	var groupID int64
+	if order.GroupID != nil {
+		groupID = *order.GroupID
 	}
-	id, err := result.LastInsertId()
+	ids, err := r.CreateOrders(ctx, order.UserID, groupID, []int{order.ProductID})
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("%d", id), nil
+	return ids[0], nil
 }
 
 // 複数の注文IDのステータスを一括で更新
 // 主に配送ロボットが注文を引き受けた際に一括更新をするために使用
-func (r *OrderRepository) UpdateStatuses(ctx context.Context, orderIDs []int64, newStatus string) error {
+// oldStatus は呼び出し側が行ロックを取って読んだ更新前のステータスで、robotID と併せてイベントに記録する
+func (r *OrderRepository) UpdateStatuses(ctx context.Context, orderIDs []int64, oldStatus, newStatus, robotID string) error {
 	if len(orderIDs) == 0 {
 		return nil
 	}
@@ -80,10 +141,101 @@ func (r *OrderRepository) UpdateStatuses(ctx context.Context, orderIDs []int64,
 		return err
 	}
 	query = r.db.Rebind(query)
-	_, err = r.db.ExecContext(ctx, query, args...)
+	if _, err = r.db.ExecContext(ctx, query, args...); err != nil {
+		return err
+	}
+	return r.appendStatusChanged(ctx, orderIDs, oldStatus, newStatus, robotID)
+}
+
+// 注文の到着日時を記録する（既に記録されている場合は変更しない）
+// 配達予定時刻の推定で、作成〜到着の所要時間の実績として使う
+func (r *OrderRepository) RecordArrival(ctx context.Context, orderID int64, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE orders SET arrived_at = ? WHERE order_id = ? AND arrived_at IS NULL", at, orderID)
 	return err
 }
 
+// 配送中(shipped_status:shipping)の注文を配送ロボットが引き受ける
+// 引き受けの間にキャンセルされた注文は更新されないため、更新件数を返して呼び出し側で確認する
+func (r *OrderRepository) ClaimShippingOrders(ctx context.Context, orderIDs []int64, robotID string) (int64, error) {
+	if len(orderIDs) == 0 {
+		return 0, nil
+	}
+	query, args, err := sqlx.In("UPDATE orders SET shipped_status = ? WHERE order_id IN (?) AND shipped_status = ?",
+		model.StatusDelivering, orderIDs, model.StatusShipping)
+	if err != nil {
+		return 0, err
+	}
+	query = r.db.Rebind(query)
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	// 一部しか引き受けられなかった場合、どの注文が更新されたか分からないためイベントは記録しない。
+	// 呼び出し側はトランザクションをロールバックすること
+	if n == int64(len(orderIDs)) {
+		if err := r.appendStatusChanged(ctx, orderIDs, model.StatusShipping, model.StatusDelivering, robotID); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// ユーザーの注文をキャンセルする
+// 配送ロボットに引き受けられる前(shipping)の注文のみ更新し、更新できたかどうかを返す
+func (r *OrderRepository) Cancel(ctx context.Context, userID int, orderID int64) (bool, error) {
+	query := "UPDATE orders SET shipped_status = ? WHERE order_id = ? AND user_id = ? AND shipped_status = ?"
+	result, err := r.db.ExecContext(ctx, query, model.StatusCancelled, orderID, userID, model.StatusShipping)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n != 1 {
+		return false, nil
+	}
+	if err := r.appendStatusChanged(ctx, []int64{orderID}, model.StatusShipping, model.StatusCancelled, ""); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ユーザーの注文を1件取得
+// 他のユーザーの注文や存在しない注文の場合は sql.ErrNoRows を返す
+func (r *OrderRepository) FindByIDForUser(ctx context.Context, userID int, orderID int64) (*model.Order, error) {
+	var order model.Order
+	query := `
+		SELECT
+			o.order_id,
+			o.user_id,
+			o.product_id,
+			o.shipped_status,
+			o.created_at,
+			o.arrived_at,
+			o.group_id,
+			p.name AS product_name
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.order_id = ? AND o.user_id = ?`
+	if err := r.db.GetContext(ctx, &order, query, orderID, userID); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// 注文の現在のステータスを行ロック付きで取得
+// ステータス変更履歴に変更前の値を記録するため、トランザクション内で呼び出すこと
+func (r *OrderRepository) GetStatusForUpdate(ctx context.Context, orderID int64) (string, error) {
+	var status string
+	err := r.db.GetContext(ctx, &status, "SELECT shipped_status FROM orders WHERE order_id = ? FOR UPDATE", orderID)
+	return status, err
+}
+
 // 配送中(shipped_status:shipping)の注文一覧を取得
 func (r *OrderRepository) GetShippingOrders(ctx context.Context) ([]model.Order, error) {
 	var orders []model.Order
@@ -119,6 +271,31 @@ func (r *OrderRepository) GetStatusesByIDs(ctx context.Context, orderIDs []int64
     return statuses, nil
 }
 
+// 注文IDから注文したユーザーIDを取得
+// 注文履歴キャッシュの破棄やステータス変更の通知先を求めるために使用
+func (r *OrderRepository) GetOwnersByOrderIDs(ctx context.Context, orderIDs []int64) (map[int64]int, error) {
+	owners := make(map[int64]int, len(orderIDs))
+	if len(orderIDs) == 0 {
+		return owners, nil
+	}
+	query, args, err := sqlx.In("SELECT order_id, user_id FROM orders WHERE order_id IN (?)", orderIDs)
+	if err != nil {
+		return nil, err
+	}
+	query = r.db.Rebind(query)
+
+	var rows []struct {
+		OrderID int64 `db:"order_id"`
+		UserID  int   `db:"user_id"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		owners[row.OrderID] = row.UserID
+	}
+	return owners, nil
+}
 
 // 注文履歴一覧を取得
 func (r *OrderRepository) ListOrders(ctx context.Context, userID int, req model.ListRequest) ([]model.Order, int, error) {
@@ -141,19 +318,10 @@ func (r *OrderRepository) ListOrders(ctx context.Context, userID int, req model.
     }
 
     // 検索条件
-    searchCond := ""
+    searchCond, filterArgs := buildOrderFilter(req)
     var args []interface{}
     args = append(args, userID)
-
-    if req.Search != "" {
-        if req.Type == "prefix" {
-            searchCond = "AND p.name LIKE ?"
-            args = append(args, req.Search+"%")
-        } else {
-            searchCond = "AND p.name LIKE ?"
-            args = append(args, "%"+req.Search+"%")
-        }
-    }
+    args = append(args, filterArgs...)
 
     // データ取得と件数取得を同時に行う1つのクエリ
     // 最初の 'args' の後に LIMIT と OFFSET 用のパラメータが続くため、
@@ -167,6 +335,7 @@ func (r *OrderRepository) ListOrders(ctx context.Context, userID int, req model.
             o.shipped_status,
             o.created_at,
             o.arrived_at,
+            o.group_id,
             p.name AS product_name,
             COUNT(*) OVER() AS total_count  -- ★ 1クエリ化のキーポイント
         FROM orders o
@@ -203,4 +372,209 @@ func (r *OrderRepository) ListOrders(ctx context.Context, userID int, req model.
     }
 
     return orders, total, nil
-}
\ No newline at end of file
+}
+
+// 注文履歴を注文ID順に1件ずつ読み出して fn に渡す
+// 全件をメモリに載せず、カーソルで読み進めながら書き出すエクスポート用
+func (r *OrderRepository) StreamOrders(ctx context.Context, userID int, req model.ListRequest, fn func(model.Order) error) error {
+	searchCond, filterArgs := buildOrderFilter(req)
+	args := append([]interface{}{userID}, filterArgs...)
+	query := fmt.Sprintf(`
+		SELECT
+			o.order_id,
+			o.product_id,
+			o.shipped_status,
+			o.created_at,
+			o.arrived_at,
+			o.group_id,
+			p.name AS product_name
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.user_id = ? %s
+		ORDER BY o.order_id ASC`, searchCond)
+
+	rows, err := r.db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var order model.Order
+		if err := rows.StructScan(&order); err != nil {
+			return err
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// 注文履歴の検索・絞り込み条件を組み立てる
+// 返す条件は "AND ..." の形で、o(orders) と p(products) の別名を前提とする
+func buildOrderFilter(req model.ListRequest) (string, []interface{}) {
+    var conds []string
+    var args []interface{}
+
+    if req.Search != "" {
+        conds = append(conds, "p.name LIKE ?")
+        if req.Type == "prefix" {
+            args = append(args, req.Search+"%")
+        } else {
+            args = append(args, "%"+req.Search+"%")
+        }
+    }
+    if len(req.ShippedStatuses) > 0 {
+        conds = append(conds, "o.shipped_status IN (?"+strings.Repeat(", ?", len(req.ShippedStatuses)-1)+")")
+        for _, st := range req.ShippedStatuses {
+            args = append(args, st)
+        }
+    }
+    if req.ProductID != nil {
+        conds = append(conds, "o.product_id = ?")
+        args = append(args, *req.ProductID)
+    }
+    if req.CreatedFrom != nil {
+        conds = append(conds, "o.created_at >= ?")
+        args = append(args, *req.CreatedFrom)
+    }
+    if req.CreatedTo != nil {
+        conds = append(conds, "o.created_at < ?")
+        args = append(args, *req.CreatedTo)
+    }
+    if req.ArrivedFrom != nil {
+        conds = append(conds, "o.arrived_at >= ?")
+        args = append(args, *req.ArrivedFrom)
+    }
+    if req.ArrivedTo != nil {
+        conds = append(conds, "o.arrived_at < ?")
+        args = append(args, *req.ArrivedTo)
+    }
+
+    if len(conds) == 0 {
+        return "", nil
+    }
+    return "AND " + strings.Join(conds, " AND "), args
+}
+
+// 配達済みの注文を到着日時の新しい順に取得
+// 配達予定時刻の推定に、作成から到着までの所要時間の実績として使う
+func (r *OrderRepository) ListDeliverySamples(ctx context.Context, limit int) ([]model.DeliverySample, error) {
+	samples := []model.DeliverySample{}
+	query := `
+		SELECT o.order_id, o.created_at, o.arrived_at, p.value, p.weight
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.shipped_status = ? AND o.arrived_at IS NOT NULL
+		ORDER BY o.arrived_at DESC
+		LIMIT ?`
+	if err := r.db.SelectContext(ctx, &samples, query, model.StatusCompleted, limit); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// since 以降に到着した注文の件数
+func (r *OrderRepository) CountArrivedSince(ctx context.Context, since time.Time) (int, error) {
+	var n int
+	query := "SELECT COUNT(*) FROM orders WHERE shipped_status = ? AND arrived_at >= ?"
+	err := r.db.GetContext(ctx, &n, query, model.StatusCompleted, since)
+	return n, err
+}
+
+// from〜to のいずれかの時点で未配達だった注文（キャンセルされたものを除く）を作成日時の古い順に取得
+// 配達予定時刻の推定精度を過去の注文で検証する際に、各時点の配送待ちの列を求めるために使う。
+// (shipped_status, arrived_at) のインデックスを使うよう、ステータスを列挙して到着日時で絞り込む
+func (r *OrderRepository) ListBacklogBetween(ctx context.Context, from, to time.Time) ([]model.BacklogOrder, error) {
+	orders := []model.BacklogOrder{}
+	statuses := []string{model.StatusShipping, model.StatusDelivering, model.StatusCompleted}
+	query, args, err := sqlx.In(`
+		SELECT o.order_id, o.created_at, o.arrived_at, p.value, p.weight
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.shipped_status IN (?) AND o.arrived_at > ? AND o.created_at <= ?
+		UNION ALL
+		SELECT o.order_id, o.created_at, o.arrived_at, p.value, p.weight
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.shipped_status IN (?) AND o.arrived_at IS NULL AND o.created_at <= ?
+		ORDER BY created_at ASC`,
+		statuses, from, to, statuses, to)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.db.SelectContext(ctx, &orders, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// ---- ドメインイベント ----
+
+// order.created の内容
+type orderCreatedEvent struct {
+	OrderID       int64  `json:"order_id"`
+	UserID        int    `json:"user_id"`
+	ProductID     int    `json:"product_id"`
+	GroupID       int64  `json:"group_id,omitempty"`
+	ShippedStatus string `json:"shipped_status"`
+}
+
+// order.status_changed の内容
+// robot_id は配送ロボットが変更した場合のみ
+type orderStatusChangedEvent struct {
+	OrderID   int64  `json:"order_id"`
+	OldStatus string `json:"old_status"`
+	NewStatus string `json:"new_status"`
+	RobotID   string `json:"robot_id,omitempty"`
+}
+
+// order_group.created の内容
+type orderGroupCreatedEvent struct {
+	GroupID  int64   `json:"group_id"`
+	UserID   int     `json:"user_id"`
+	OrderIDs []int64 `json:"order_ids"`
+}
+
+func (r *OrderRepository) appendStatusChanged(ctx context.Context, orderIDs []int64, oldStatus, newStatus, robotID string) error {
+	now := time.Now()
+	evs := make([]model.DomainEvent, len(orderIDs))
+	for i, id := range orderIDs {
+		payload, err := json.Marshal(orderStatusChangedEvent{OrderID: id, OldStatus: oldStatus, NewStatus: newStatus, RobotID: robotID})
+		if err != nil {
+			return err
+		}
+		evs[i] = orderEvent(id, model.DomainEventOrderStatusChanged, payload, now)
+	}
+	return r.events.Append(ctx, evs)
+}
+
+// 注文グループの作成を記録する（注文IDは firstID からの連番）
+func (r *OrderRepository) appendGroupCreated(ctx context.Context, userID int, groupID, firstID int64, n int) error {
+	orderIDs := make([]int64, n)
+	for i := range orderIDs {
+		orderIDs[i] = firstID + int64(i)
+	}
+	payload, err := json.Marshal(orderGroupCreatedEvent{GroupID: groupID, UserID: userID, OrderIDs: orderIDs})
+	if err != nil {
+		return err
+	}
+	return r.events.Append(ctx, []model.DomainEvent{{
+		AggregateType: model.AggregateOrderGroup,
+		AggregateID:   groupID,
+		EventType:     model.DomainEventOrderGroupCreated,
+		Payload:       payload,
+		OccurredAt:    time.Now(),
+	}})
+}
+
+func orderEvent(orderID int64, eventType string, payload []byte, occurredAt time.Time) model.DomainEvent {
+	return model.DomainEvent{
+		AggregateType: model.AggregateOrder,
+		AggregateID:   orderID,
+		EventType:     eventType,
+		Payload:       payload,
+		OccurredAt:    occurredAt,
+	}
+}
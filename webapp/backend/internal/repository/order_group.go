@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type OrderGroupRepository struct {
+	db DBTX
+}
+
+func NewOrderGroupRepository(db DBTX) *OrderGroupRepository {
+	return &OrderGroupRepository{db: db}
+}
+
+// 注文グループを作成し、グループIDを返す
+func (r *OrderGroupRepository) Create(ctx context.Context, userID int) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		"INSERT INTO order_groups (user_id, created_at) VALUES (?, ?)", userID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// ユーザーの注文グループを新しい順に取得
+// 商品ごとの内訳と進捗は含まないため、ListItems で取得する
+func (r *OrderGroupRepository) List(ctx context.Context, userID, limit, offset int) ([]model.OrderGroup, int, error) {
+	groups := []model.OrderGroup{}
+	query := `
+		SELECT group_id, created_at
+		FROM order_groups
+		WHERE user_id = ?
+		ORDER BY group_id DESC
+		LIMIT ? OFFSET ?`
+	if err := r.db.SelectContext(ctx, &groups, query, userID, limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM order_groups WHERE user_id = ?", userID); err != nil {
+		return nil, 0, err
+	}
+	return groups, total, nil
+}
+
+// ユーザーの注文グループを1件取得
+// 他のユーザーのグループや存在しないグループの場合は sql.ErrNoRows を返す
+func (r *OrderGroupRepository) FindForUser(ctx context.Context, userID int, groupID int64) (*model.OrderGroup, error) {
+	var group model.OrderGroup
+	query := "SELECT group_id, created_at FROM order_groups WHERE group_id = ? AND user_id = ?"
+	if err := r.db.GetContext(ctx, &group, query, groupID, userID); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// 注文グループ内の商品ごとの数量とステータス別の件数を取得
+func (r *OrderGroupRepository) ListItems(ctx context.Context, groupIDs []int64) ([]model.OrderGroupItem, error) {
+	items := []model.OrderGroupItem{}
+	if len(groupIDs) == 0 {
+		return items, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT
+			o.group_id,
+			o.product_id,
+			p.name AS product_name,
+			COUNT(*) AS quantity,
+			SUM(o.shipped_status = 'shipping') AS shipping,
+			SUM(o.shipped_status = 'delivering') AS delivering,
+			SUM(o.shipped_status = 'completed') AS completed,
+			SUM(o.shipped_status = 'cancelled') AS cancelled
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.group_id IN (?)
+		GROUP BY o.group_id, o.product_id, p.name
+		ORDER BY o.group_id DESC, MIN(o.order_id) ASC`, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+	query = r.db.Rebind(query)
+	if err := r.db.SelectContext(ctx, &items, query, args...); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+)
+
+// 注文IDを order_id_sequence テーブルから範囲で確保する
+// innodb_autoinc_lock_mode によらず、返したIDは他の接続と重複しない
+type OrderIDSequence struct {
+	db DBTX
+}
+
+func NewOrderIDSequence(db DBTX) *OrderIDSequence {
+	return &OrderIDSequence{db: db}
+}
+
+// n 個の連続した注文IDを確保し、先頭のIDを返す
+// LAST_INSERT_ID(expr) の値は同じ文の結果として返るため、接続をまたいでも正しく取得できる
+func (s *OrderIDSequence) Reserve(ctx context.Context, n int) (int64, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid order id reservation size: %d", n)
+	}
+	result, err := s.db.ExecContext(ctx,
+		"UPDATE order_id_sequence SET last_id = LAST_INSERT_ID(last_id + ?) WHERE id = 1", n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reserve order ids: %w", err)
+	}
+	lastID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return lastID - int64(n) + 1, nil
+}
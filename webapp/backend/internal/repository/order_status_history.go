@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/internal/model"
+)
+
+// 1回の INSERT 文で挿入する最大行数
+const statusHistoryInsertBatchSize = 1000
+
+type OrderStatusHistoryRepository struct {
+	db DBTX
+}
+
+func NewOrderStatusHistoryRepository(db DBTX) *OrderStatusHistoryRepository {
+	return &OrderStatusHistoryRepository{db: db}
+}
+
+// ステータス変更を履歴に記録する
+// 注文の更新と同じトランザクション内で呼び出すこと
+func (r *OrderStatusHistoryRepository) Record(ctx context.Context, entries []model.OrderStatusHistory) error {
+	for start := 0; start < len(entries); start += statusHistoryInsertBatchSize {
+		end := start + statusHistoryInsertBatchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		query := "INSERT INTO order_status_history (order_id, old_status, new_status, actor, changed_at) VALUES "
+		var args []interface{}
+		var placeholders []string
+		for _, e := range entries[start:end] {
+			placeholders = append(placeholders, "(?, ?, ?, ?, ?)")
+			args = append(args, e.OrderID, e.OldStatus, e.NewStatus, e.Actor, e.ChangedAt)
+		}
+		query += strings.Join(placeholders, ",")
+
+		if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to insert order status history: %w", err)
+		}
+	}
+	return nil
+}
+
+// 注文のステータス変更履歴を古い順に取得
+func (r *OrderStatusHistoryRepository) ListByOrderID(ctx context.Context, orderID int64) ([]model.OrderStatusHistory, error) {
+	history := []model.OrderStatusHistory{}
+	query := `
+		SELECT order_id, old_status, new_status, actor, changed_at
+		FROM order_status_history
+		WHERE order_id = ?
+		ORDER BY changed_at ASC, id ASC`
+	if err := r.db.SelectContext(ctx, &history, query, orderID); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// 引き受け(delivering)から配達完了(completed)までの所要時間の実績を新しい順に取得
+func (r *OrderStatusHistoryRepository) ListTransitDurations(ctx context.Context, limit int) ([]time.Duration, error) {
+	var micros []int64
+	query := `
+		SELECT TIMESTAMPDIFF(MICROSECOND, d.changed_at, c.changed_at)
+		FROM order_status_history c
+		JOIN order_status_history d ON d.order_id = c.order_id AND d.new_status = ?
+		WHERE c.new_status = ?
+		ORDER BY c.id DESC
+		LIMIT ?`
+	if err := r.db.SelectContext(ctx, &micros, query, model.StatusDelivering, model.StatusCompleted, limit); err != nil {
+		return nil, err
+	}
+	durations := make([]time.Duration, len(micros))
+	for i, us := range micros {
+		durations[i] = time.Duration(us) * time.Microsecond
+	}
+	return durations, nil
+}
+
+// 配送中(delivering)の注文を引き受けた日時を注文ごとに取得
+func (r *OrderStatusHistoryRepository) ListDeliveringClaimTimes(ctx context.Context) (map[int64]time.Time, error) {
+	var rows []struct {
+		OrderID   int64     `db:"order_id"`
+		ChangedAt time.Time `db:"changed_at"`
+	}
+	query := `
+		SELECT h.order_id, MAX(h.changed_at) AS changed_at
+		FROM orders o
+		JOIN order_status_history h ON h.order_id = o.order_id AND h.new_status = ?
+		WHERE o.shipped_status = ?
+		GROUP BY h.order_id`
+	if err := r.db.SelectContext(ctx, &rows, query, model.StatusDelivering, model.StatusDelivering); err != nil {
+		return nil, err
+	}
+	result := make(map[int64]time.Time, len(rows))
+	for _, row := range rows {
+		result[row.OrderID] = row.ChangedAt
+	}
+	return result, nil
+}
@@ -5,6 +5,8 @@ import (
 	"context"
 	"strings"
 	"fmt"
+
+	"github.com/jmoiron/sqlx"
 )
 
 type ProductRepository struct {
@@ -181,3 +183,30 @@ func (r *ProductRepository) ListProducts(
 
 	return products, total, nil
 }
+
+// 指定した商品IDのうち存在するものを返す
+func (r *ProductRepository) FindExistingIDs(ctx context.Context, productIDs []int) ([]int, error) {
+	if len(productIDs) == 0 {
+		return []int{}, nil
+	}
+	query, args, err := sqlx.In("SELECT product_id FROM products WHERE product_id IN (?)", productIDs)
+	if err != nil {
+		return nil, err
+	}
+	query = r.db.Rebind(query)
+
+	var ids []int
+	if err := r.db.SelectContext(ctx, &ids, query, args...); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// 商品が参照している画像のパスを返す
+func (r *ProductRepository) ListImagePaths(ctx context.Context) ([]string, error) {
+	var paths []string
+	if err := r.db.SelectContext(ctx, &paths, "SELECT DISTINCT image FROM products WHERE image <> ''"); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/internal/model"
+)
+
+type RecommendationRepository struct {
+	db DBTX
+}
+
+func NewRecommendationRepository(db DBTX) *RecommendationRepository {
+	return &RecommendationRepository{db: db}
+}
+
+// 1回の INSERT 文で挿入する最大行数
+const recommendationInsertBatchSize = 1000
+
+// ユーザーが購入した商品の組をユーザーID順に1件ずつ fn に渡す
+// ユーザーごとに最後に購入した日時の新しい商品から渡す。キャンセルされた注文は購入に含めない
+func (r *RecommendationRepository) StreamUserProducts(ctx context.Context, fn func(userID, productID int) error) error {
+	query := `
+		SELECT user_id, product_id
+		FROM orders
+		WHERE shipped_status <> ?
+		GROUP BY user_id, product_id
+		ORDER BY user_id, MAX(created_at) DESC, product_id`
+	rows, err := r.db.QueryxContext(ctx, query, model.StatusCancelled)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, productID int
+		if err := rows.Scan(&userID, &productID); err != nil {
+			return err
+		}
+		if err := fn(userID, productID); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// 類似度を入れ替える
+// 入れ替えの途中を読まれないよう、トランザクション内で呼び出すこと
+func (r *RecommendationRepository) ReplaceSimilarities(ctx context.Context, sims []model.ProductSimilarity, computedAt time.Time) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM product_similarities"); err != nil {
+		return err
+	}
+	for start := 0; start < len(sims); start += recommendationInsertBatchSize {
+		end := start + recommendationInsertBatchSize
+		if end > len(sims) {
+			end = len(sims)
+		}
+
+		query := "INSERT INTO product_similarities (product_id, related_product_id, score, co_purchases, computed_at) VALUES "
+		var args []interface{}
+		var placeholders []string
+		for _, s := range sims[start:end] {
+			placeholders = append(placeholders, "(?, ?, ?, ?, ?)")
+			args = append(args, s.ProductID, s.RelatedProductID, s.Score, s.CoPurchases, computedAt)
+		}
+		query += strings.Join(placeholders, ",")
+
+		if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to insert product similarities: %w", err)
+		}
+	}
+	return nil
+}
+
+// 人気度を入れ替える
+// 入れ替えの途中を読まれないよう、トランザクション内で呼び出すこと
+func (r *RecommendationRepository) ReplacePopularity(ctx context.Context, pops []model.ProductPopularity, computedAt time.Time) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM product_popularity"); err != nil {
+		return err
+	}
+	for start := 0; start < len(pops); start += recommendationInsertBatchSize {
+		end := start + recommendationInsertBatchSize
+		if end > len(pops) {
+			end = len(pops)
+		}
+
+		query := "INSERT INTO product_popularity (product_id, buyer_count, score, computed_at) VALUES "
+		var args []interface{}
+		var placeholders []string
+		for _, p := range pops[start:end] {
+			placeholders = append(placeholders, "(?, ?, ?, ?)")
+			args = append(args, p.ProductID, p.BuyerCount, p.Score, computedAt)
+		}
+		query += strings.Join(placeholders, ",")
+
+		if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to insert product popularity: %w", err)
+		}
+	}
+	return nil
+}
+
+// 商品と一緒に購入されている商品を類似度の高い順に取得
+func (r *RecommendationRepository) ListRelated(ctx context.Context, productID, limit int) ([]model.RecommendedProduct, error) {
+	products := []model.RecommendedProduct{}
+	query := `
+		SELECT p.product_id, p.name, p.value, p.weight, p.image, p.description, s.score
+		FROM product_similarities s
+		JOIN products p ON s.related_product_id = p.product_id
+		WHERE s.product_id = ?
+		ORDER BY s.score DESC, p.product_id ASC
+		LIMIT ?`
+	if err := r.db.SelectContext(ctx, &products, query, productID, limit); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// ユーザーが購入した商品と一緒に購入されている、未購入の商品を取得
+// 購入した各商品との類似度の合計が高い順に並べる
+func (r *RecommendationRepository) ListForUser(ctx context.Context, userID, limit int) ([]model.RecommendedProduct, error) {
+	products := []model.RecommendedProduct{}
+	query := `
+		SELECT p.product_id, p.name, p.value, p.weight, p.image, p.description, SUM(s.score) AS score
+		FROM product_similarities s
+		JOIN products p ON s.related_product_id = p.product_id
+		WHERE s.product_id IN (
+				SELECT product_id FROM orders WHERE user_id = ? AND shipped_status <> ?
+			)
+			AND s.related_product_id NOT IN (
+				SELECT product_id FROM orders WHERE user_id = ?
+			)
+		GROUP BY p.product_id, p.name, p.value, p.weight, p.image, p.description
+		ORDER BY score DESC, p.product_id ASC
+		LIMIT ?`
+	if err := r.db.SelectContext(ctx, &products, query, userID, model.StatusCancelled, userID, limit); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// 人気商品を取得
+// userID が 0 でなければそのユーザーが購入済みの商品を、excludeIDs の商品を除く
+func (r *RecommendationRepository) ListPopular(ctx context.Context, userID int, excludeIDs []int, limit int) ([]model.RecommendedProduct, error) {
+	products := []model.RecommendedProduct{}
+	query := `
+		SELECT p.product_id, p.name, p.value, p.weight, p.image, p.description, pp.score
+		FROM product_popularity pp
+		JOIN products p ON pp.product_id = p.product_id
+		WHERE NOT EXISTS (
+			SELECT 1 FROM orders o WHERE o.user_id = ? AND o.product_id = pp.product_id
+		)`
+	args := []interface{}{userID}
+	if len(excludeIDs) > 0 {
+		query += " AND pp.product_id NOT IN (?" + strings.Repeat(", ?", len(excludeIDs)-1) + ")"
+		for _, id := range excludeIDs {
+			args = append(args, id)
+		}
+	}
+	query += " ORDER BY pp.score DESC, p.product_id ASC LIMIT ?"
+	args = append(args, limit)
+
+	if err := r.db.SelectContext(ctx, &products, query, args...); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
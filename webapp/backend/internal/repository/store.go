@@ -7,20 +7,36 @@ import (
 )
 
 type Store struct {
-	db          DBTX
-	UserRepo    *UserRepository
-	SessionRepo *SessionRepository
-	ProductRepo *ProductRepository
-	OrderRepo   *OrderRepository
+	db              DBTX
+	UserRepo        *UserRepository
+	SessionRepo     *SessionRepository
+	ProductRepo     *ProductRepository
+	OrderRepo       *OrderRepository
+	IdempotencyRepo *IdempotencyRepository
+	StatusHistRepo  *OrderStatusHistoryRepository
+	CartRepo        *CartRepository
+	OrderGroupRepo  *OrderGroupRepository
+	WebhookRepo     *WebhookRepository
+	DomainEventRepo *DomainEventRepository
+	RecommendRepo   *RecommendationRepository
+	ImageRepo       *ImageRepository
 }
 
 func NewStore(db DBTX) *Store {
 	return &Store{
-		db:          db,
-		UserRepo:    NewUserRepository(db),
-		SessionRepo: NewSessionRepository(db),
-		ProductRepo: NewProductRepository(db),
-		OrderRepo:   NewOrderRepository(db),
+		db:              db,
+		UserRepo:        NewUserRepository(db),
+		SessionRepo:     NewSessionRepository(db),
+		ProductRepo:     NewProductRepository(db),
+		OrderRepo:       NewOrderRepository(db),
+		IdempotencyRepo: NewIdempotencyRepository(db),
+		StatusHistRepo:  NewOrderStatusHistoryRepository(db),
+		CartRepo:        NewCartRepository(db),
+		OrderGroupRepo:  NewOrderGroupRepository(db),
+		WebhookRepo:     NewWebhookRepository(db),
+		DomainEventRepo: NewDomainEventRepository(db),
+		RecommendRepo:   NewRecommendationRepository(db),
+		ImageRepo:       NewImageRepository(db),
 	}
 }
 
@@ -37,6 +53,8 @@ func (s *Store) ExecTx(ctx context.Context, fn func(txStore *Store) error) error
 	defer tx.Rollback()
 
 	txStore := NewStore(tx)
+	// 注文IDの採番はトランザクション外で行い、採番用の行ロックを保持し続けないようにする
+	txStore.OrderRepo.idSeq = s.OrderRepo.idSeq
 	if err := fn(txStore); err != nil {
 		return err
 	}
@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type WebhookRepository struct {
+	db DBTX
+}
+
+func NewWebhookRepository(db DBTX) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// ---- 通知先 ----
+
+func (r *WebhookRepository) CreateEndpoint(ctx context.Context, ep *model.WebhookEndpoint) (int64, error) {
+	query := `
+		INSERT INTO webhook_endpoints (url, secret, event_types, active, created_at)
+		VALUES (?, ?, ?, ?, ?)`
+	result, err := r.db.ExecContext(ctx, query, ep.URL, ep.Secret, ep.EventTypes, ep.Active, ep.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *WebhookRepository) ListEndpoints(ctx context.Context) ([]model.WebhookEndpoint, error) {
+	endpoints := []model.WebhookEndpoint{}
+	query := `
+		SELECT endpoint_id, url, secret, event_types, active, created_at
+		FROM webhook_endpoints
+		ORDER BY endpoint_id ASC`
+	if err := r.db.SelectContext(ctx, &endpoints, query); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (r *WebhookRepository) ListActiveEndpoints(ctx context.Context) ([]model.WebhookEndpoint, error) {
+	endpoints := []model.WebhookEndpoint{}
+	query := `
+		SELECT endpoint_id, url, secret, event_types, active, created_at
+		FROM webhook_endpoints
+		WHERE active = 1`
+	if err := r.db.SelectContext(ctx, &endpoints, query); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// 通知先を無効にし、無効にできたかどうかを返す
+// 配送ログを残すため行は削除しない
+func (r *WebhookRepository) DeactivateEndpoint(ctx context.Context, endpointID int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, "UPDATE webhook_endpoints SET active = 0 WHERE endpoint_id = ? AND active = 1", endpointID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// ---- 配送 ----
+
+// イベントと通知先の組ごとに配送を作成する（作成済みの組は無視する）
+// URL と Secret は使わず、送信時に通知先から読む
+func (r *WebhookRepository) CreateDeliveries(ctx context.Context, deliveries []model.WebhookDeliveryJob) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	query := `
+		INSERT IGNORE INTO webhook_deliveries
+			(event_id, endpoint_id, event_type, payload, event_created_at, status, attempts, next_attempt_at, created_at, updated_at)
+		VALUES `
+	var args []interface{}
+	var placeholders []string
+	for _, d := range deliveries {
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, 0, ?, ?, ?)")
+		// []byte のままだと binary 文字セットとして送られ JSON 列に入らないため文字列で渡す
+		args = append(args, d.EventID, d.EndpointID, d.EventType, string(d.Payload), d.EventTime,
+			model.WebhookDeliveryPending, d.NextAttemptAt, d.CreatedAt, d.CreatedAt)
+	}
+	query += strings.Join(placeholders, ",")
+	_, err := r.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// 送信時刻を迎えた配送を行ロック付きで取得
+// 他のインスタンスがロック中の配送は読み飛ばす。トランザクション内で呼び出すこと
+func (r *WebhookRepository) ClaimDueDeliveries(ctx context.Context, now time.Time, limit int) ([]model.WebhookDeliveryJob, error) {
+	jobs := []model.WebhookDeliveryJob{}
+	query := `
+		SELECT
+			d.delivery_id,
+			d.event_id,
+			d.endpoint_id,
+			d.event_type,
+			d.status,
+			d.attempts,
+			d.next_attempt_at,
+			d.last_status_code,
+			d.last_error,
+			d.created_at,
+			d.updated_at,
+			e.url,
+			e.secret,
+			d.payload,
+			d.event_created_at
+		FROM webhook_deliveries d
+		JOIN webhook_endpoints e ON d.endpoint_id = e.endpoint_id
+		WHERE d.status = ? AND d.next_attempt_at <= ? AND e.active = 1
+		ORDER BY d.next_attempt_at ASC
+		LIMIT ?
+		FOR UPDATE OF d SKIP LOCKED`
+	if err := r.db.SelectContext(ctx, &jobs, query, model.WebhookDeliveryPending, now, limit); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// 配送の次回送信時刻をずらす
+// 送信中に他のインスタンスが同じ配送を取得しないようにするために使う
+func (r *WebhookRepository) LeaseDeliveries(ctx context.Context, deliveryIDs []int64, until time.Time) error {
+	if len(deliveryIDs) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In("UPDATE webhook_deliveries SET next_attempt_at = ? WHERE delivery_id IN (?)", until, deliveryIDs)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, r.db.Rebind(query), args...)
+	return err
+}
+
+// 送信結果を配送とログに記録する
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, d *model.WebhookDelivery, attempt model.WebhookDeliveryAttempt) error {
+	now := time.Now()
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, updated_at = ?
+		WHERE delivery_id = ?`,
+		d.Status, d.Attempts, d.NextAttemptAt, nullableInt(d.LastStatusCode), nullableString(d.LastError), now,
+		d.DeliveryID)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, error, duration_ms, attempted_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		attempt.DeliveryID, attempt.Attempt, nullableInt(attempt.StatusCode), nullableString(attempt.Error),
+		attempt.DurationMS, attempt.AttemptedAt)
+	return err
+}
+
+// 通知先の配送状況を新しい順に取得
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, endpointID int64, limit int) ([]model.WebhookDelivery, error) {
+	deliveries := []model.WebhookDelivery{}
+	query := `
+		SELECT
+			delivery_id,
+			event_id,
+			endpoint_id,
+			event_type,
+			status,
+			attempts,
+			next_attempt_at,
+			last_status_code,
+			last_error,
+			created_at,
+			updated_at
+		FROM webhook_deliveries
+		WHERE endpoint_id = ?
+		ORDER BY delivery_id DESC
+		LIMIT ?`
+	if err := r.db.SelectContext(ctx, &deliveries, query, endpointID, limit); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// 配送の試行ログを古い順に取得
+func (r *WebhookRepository) ListAttempts(ctx context.Context, deliveryID int64) ([]model.WebhookDeliveryAttempt, error) {
+	attempts := []model.WebhookDeliveryAttempt{}
+	query := `
+		SELECT delivery_id, attempt, status_code, error, duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = ?
+		ORDER BY attempt ASC`
+	if err := r.db.SelectContext(ctx, &attempts, query, deliveryID); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// 送信が済んだ（成功または失敗が確定した）配送のうち、before より前に更新されたものを削除する
+// 試行ログも併せて削除される
+func (r *WebhookRepository) DeleteFinishedDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM webhook_deliveries WHERE status IN (?, ?) AND updated_at < ?",
+		model.WebhookDeliverySucceeded, model.WebhookDeliveryFailed, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func nullableInt(v *int) sql.NullInt64 {
+	if v == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*v), Valid: true}
+}
+
+func nullableString(v *string) sql.NullString {
+	if v == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *v, Valid: true}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ctx がキャンセルされるまでリクエストを受け付ける
+// キャンセル後は ShutdownDelay の後に新しい接続を止め、処理中のリクエストの完了を ShutdownTimeout まで待つ
+func (s *Server) Run(ctx context.Context) error {
+	cfg := s.httpConfig
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           s.Router,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "addr", srv.Addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to start server: %w", err)
+	case <-ctx.Done():
+	}
+
+	// readiness を失敗させ、ロードバランサーが振り分けをやめるまで待ってから接続を止める
+	close(s.shutdown)
+	if cfg.ShutdownDelay > 0 {
+		slog.Info("Shutting down server after delay", "delay", cfg.ShutdownDelay)
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
+	slog.Info("Shutting down server", "timeout", cfg.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("failed to drain in-flight requests: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("Server stopped")
+	return nil
+}
+
+// バックグラウンドの処理を開始する
+// 終了は NewServer に渡したコンテキストのキャンセルで伝え、Wait で待つ
+func (s *Server) startWorker(fn func()) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		fn()
+	}()
+}
+
+// バックグラウンドの処理の終了を timeout まで待つ
+func (s *Server) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
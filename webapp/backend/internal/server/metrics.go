@@ -0,0 +1,35 @@
+package server
+
+import (
+	"backend/internal/imagestore"
+	"backend/internal/service"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 他の箇所で数えている統計を /metrics に公開する
+// 接続プールの統計は go_sql_* として公開される
+func registerMetrics(dbConn *sqlx.DB, orderService *service.OrderService, imageStore *imagestore.Store) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(dbConn.DB, "backend"))
+
+	promauto.NewCounterFunc(prometheus.CounterOpts{Name: "order_cache_hits_total", Help: "Order history cache hits."},
+		func() float64 { return float64(orderService.CacheStats().Hits) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{Name: "order_cache_misses_total", Help: "Order history cache misses."},
+		func() float64 { return float64(orderService.CacheStats().Misses) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{Name: "order_cache_evictions_total", Help: "Order history cache evictions."},
+		func() float64 { return float64(orderService.CacheStats().Evictions) })
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{Name: "order_cache_entries", Help: "Entries in the order history cache."},
+		func() float64 { return float64(orderService.CacheStats().Size) })
+
+	if imageStore != nil {
+		promauto.NewCounterFunc(prometheus.CounterOpts{Name: "image_cache_hits_total", Help: "Image store memory cache hits."},
+			func() float64 { return float64(imageStore.CacheStats().Hits) })
+		promauto.NewCounterFunc(prometheus.CounterOpts{Name: "image_cache_misses_total", Help: "Image store memory cache misses."},
+			func() float64 { return float64(imageStore.CacheStats().Misses) })
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{Name: "image_cache_bytes", Help: "Bytes held in the image store memory cache."},
+			func() float64 { return float64(imageStore.CacheStats().Bytes) })
+	}
+}
@@ -1,70 +1,197 @@
 package server
 
 import (
+	"backend/internal/apierror"
+	"backend/internal/config"
 	"backend/internal/db"
+	"backend/internal/events"
 	"backend/internal/handler"
+	"backend/internal/imagestore"
+	"backend/internal/logging"
 	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/outbox"
 	"backend/internal/repository"
 	"backend/internal/service"
-	"log"
+	"backend/internal/thumbnail"
+	"backend/internal/webhook"
+	"context"
+	"encoding/json"
+	"log/slog"
 	"net/http"
-	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/riandyrn/otelchi"
 )
 
 type Server struct {
-	Router *chi.Mux
+	Router     *chi.Mux
+	httpConfig config.HTTP
+	// 停止を始めたときに閉じる
+	shutdown chan struct{}
+	workers  sync.WaitGroup
 }
 
-func NewServer() (*Server, *sqlx.DB, error) {
-	dbConn, err := db.InitDBConnection()
+// ctx はバックグラウンドの処理に渡し、キャンセルで停止させる
+func NewServer(ctx context.Context, cfg *config.Config) (*Server, *sqlx.DB, error) {
+	dbConn, err := db.InitDBConnection(cfg.Database, cfg.Telemetry.On())
 	if err != nil {
 		return nil, nil, err
 	}
 
 	store := repository.NewStore(dbConn)
 
+	orderCache := service.NewOrderCache(cfg.OrderCache.MaxEntries, cfg.OrderCache.TTL)
+	statusHub := events.NewHub()
+	etaEstimator := service.NewETAEstimator(store)
+
 	authService := service.NewAuthService(store)
-	orderService := service.NewOrderService(store)
-	productService := service.NewProductService(store)
-	robotService := service.NewRobotService(store)
+	orderService := service.NewOrderService(store, orderCache, statusHub, etaEstimator)
+	productService := service.NewProductService(store, orderCache)
+	robotService := service.NewRobotService(store, orderCache, statusHub)
+	cartService := service.NewCartService(store, productService, orderCache)
+	webhookService := service.NewWebhookService(store)
+	recommendationService := service.NewRecommendationService(store)
+
+	// image_store.kind が設定されている場合は、内容のハッシュで管理するストアから画像を配信する
+	var imageStore *imagestore.Store
+	imageBackend, err := imagestore.NewBackend(cfg.ImageStore)
+	if err != nil {
+		dbConn.Close()
+		return nil, nil, err
+	}
+	if imageBackend != nil {
+		imageStore = imagestore.New(imageBackend, store.ImageRepo, cfg.ImageStore.CacheBytes)
+	}
+	thumbnails, err := thumbnail.NewGenerator(cfg.Thumbnail.CacheDir, cfg.Thumbnail.CacheBytes)
+	if err != nil {
+		dbConn.Close()
+		return nil, nil, err
+	}
+
+	s := &Server{
+		httpConfig: cfg.HTTP,
+		shutdown:   make(chan struct{}),
+	}
 
 	authHandler := handler.NewAuthHandler(authService)
-	productHandler := handler.NewProductHandler(productService)
+	productHandler := handler.NewProductHandler(productService, imageStore, thumbnails)
+	nImages, err := productHandler.LoadImageAllowlist(context.Background())
+	if err != nil {
+		dbConn.Close()
+		return nil, nil, err
+	}
+	slog.Info("Loaded product image paths", "paths", nImages)
 	orderHandler := handler.NewOrderHandler(orderService)
 	robotHandler := handler.NewRobotHandler(robotService)
+	cartHandler := handler.NewCartHandler(cartService)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	recommendationHandler := handler.NewRecommendationHandler(recommendationService)
+	healthHandler := handler.NewHealthHandler(service.NewHealthService(dbConn, cfg.Health.PingTimeout), s.shutdown)
 
 	userAuthMW := middleware.UserAuthMiddleware(store.SessionRepo)
 
-	robotAPIKey := os.Getenv("ROBOT_API_KEY")
-	if robotAPIKey == "" {
-		log.Println("Warning: ROBOT_API_KEY is not set. Using default key 'test-robot-key'")
-		robotAPIKey = "test-robot-key"
+	if cfg.Robot.APIKey == config.DefaultRobotAPIKey {
+		slog.Warn("ROBOT_API_KEY is not set. Using default key 'test-robot-key'")
 	}
-	robotAuthMW := middleware.RobotAuthMiddleware(robotAPIKey)
+	robotAuthMW := middleware.RobotAuthMiddleware(cfg.Robot.APIKey)
 
 	r := chi.NewRouter()
 	r.Use(otelchi.Middleware(
 		"backend-api",
 		otelchi.WithChiRoutes(r),
 		otelchi.WithFilter(func(req *http.Request) bool {
-			return req.URL.Path != "/api/health"
+			return !strings.HasPrefix(req.URL.Path, "/api/health") && req.URL.Path != "/metrics"
 		}),
 	))
+	r.Use(middleware.RequestID)
+	r.Use(middleware.Metrics)
+
+	// ルートがない場合もエラーは JSON で返す
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "Not found")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed")
+	})
 
 	r.Get("/api/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
+	r.Get("/api/health/live", healthHandler.Live)
+	r.Get("/api/health/ready", healthHandler.Ready)
+
+	registerMetrics(dbConn, orderService, imageStore)
+	r.Method(http.MethodGet, "/metrics", promhttp.Handler())
+
+	s.Router = r
+
+	s.setupRoutes(authHandler, productHandler, orderHandler, robotHandler, cartHandler, recommendationHandler, userAuthMW, robotAuthMW)
+
+	// 運用確認用（ロボットと同じAPIキーで保護）
+	s.Router.With(robotAuthMW).Get("/api/internal/cache-stats", func(w http.ResponseWriter, r *http.Request) {
+		stats := map[string]interface{}{
+			"orders": orderService.CacheStats(),
+		}
+		if imageStore != nil {
+			stats["images"] = imageStore.CacheStats()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+	})
 
-	s := &Server{
-		Router: r,
-	}
+	// Webhook の通知先の管理と配送ログ（ロボットと同じAPIキーで保護）
+	s.Router.Route("/api/internal/webhooks", func(r chi.Router) {
+		r.Use(robotAuthMW)
+		r.Post("/", webhookHandler.CreateEndpoint)
+		r.Get("/", webhookHandler.ListEndpoints)
+		r.Delete("/{id}", webhookHandler.DeleteEndpoint)
+		r.Get("/{id}/deliveries", webhookHandler.ListDeliveries)
+		r.Get("/deliveries/{deliveryID}/attempts", webhookHandler.ListAttempts)
+	})
 
-	s.setupRoutes(authHandler, productHandler, orderHandler, robotHandler, userAuthMW, robotAuthMW)
+	// 配達予定時刻の推定精度（過去の注文で検証）
+	s.Router.With(robotAuthMW).Get("/api/internal/eta-accuracy", func(w http.ResponseWriter, r *http.Request) {
+		var samples int
+		if v := r.URL.Query().Get("samples"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Query parameter 'samples' must be an integer")
+				return
+			}
+			samples = n
+		}
+		acc, err := orderService.ETAAccuracy(r.Context(), samples)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to measure ETA accuracy", "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to measure ETA accuracy")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(acc)
+	})
+
+	// 失敗しうる準備がすべて終わってから、バックグラウンドの処理を始める
+	s.startWorker(func() { productService.RunIdempotencyKeyPurger(ctx, cfg.Idempotency.PurgeInterval) })
+	s.startWorker(func() { webhook.NewDispatcher(store, nil).Run(ctx) })
+	s.startWorker(func() {
+		webhookService.RunDeliveryPurger(ctx, cfg.Webhook.PurgeInterval, cfg.Webhook.DeliveryRetention)
+	})
+	bus := outbox.NewBus()
+	bus.Subscribe(func(ctx context.Context, ev model.DomainEvent) error {
+		slog.Debug("Domain event", "event_id", ev.EventID, "type", ev.EventType, "aggregate_id", ev.AggregateID)
+		return nil
+	})
+	relay := outbox.NewRelay(store, domainEventSinks(store, bus, cfg.DomainEvents)...)
+	s.startWorker(func() { relay.Run(ctx) })
+	s.startWorker(func() { relay.RunPurger(ctx, cfg.DomainEvents.PurgeInterval, cfg.DomainEvents.Retention) })
+	s.startWorker(func() { recommendationService.RunRefresher(ctx, cfg.Recommendation.RefreshInterval) })
 
 	return s, dbConn, nil
 }
@@ -74,6 +201,8 @@ func (s *Server) setupRoutes(
 	productHandler *handler.ProductHandler,
 	orderHandler *handler.OrderHandler,
 	robotHandler *handler.RobotHandler,
+	cartHandler *handler.CartHandler,
+	recommendationHandler *handler.RecommendationHandler,
 	userAuthMW func(http.Handler) http.Handler,
 	robotAuthMW func(http.Handler) http.Handler,
 ) {
@@ -83,8 +212,23 @@ func (s *Server) setupRoutes(
 		r.Use(userAuthMW)
 		r.Post("/product", productHandler.List)
 		r.Post("/product/post", productHandler.CreateOrders)
+		r.Get("/product/{id}/related", recommendationHandler.Related)
+		r.Get("/recommendations", recommendationHandler.ForUser)
 		r.Post("/orders", orderHandler.List)
+		r.With(middleware.WriteTimeout(s.httpConfig.ExportWriteTimeout)).Get("/orders/export", orderHandler.Export)
+		// SSE は書き込みの期限をなくし、停止時には切断する
+		r.With(middleware.WriteTimeout(0), middleware.CancelOn(s.shutdown)).Get("/orders/events", orderHandler.Events)
+		r.Get("/orders/{id}", orderHandler.Get)
+		r.Post("/orders/{id}/cancel", orderHandler.Cancel)
+		r.Post("/order-groups", orderHandler.ListGroups)
+		r.Get("/order-groups/{id}", orderHandler.GetGroup)
 		r.Get("/image", productHandler.GetImage)
+
+		r.Get("/cart", cartHandler.Get)
+		r.Post("/cart/items", cartHandler.AddItem)
+		r.Put("/cart/items/{productID}", cartHandler.UpdateItem)
+		r.Delete("/cart/items/{productID}", cartHandler.RemoveItem)
+		r.Post("/cart/checkout", cartHandler.Checkout)
 	})
 
 	s.Router.Route("/api/robot", func(r chi.Router) {
@@ -94,14 +238,15 @@ func (s *Server) setupRoutes(
 	})
 }
 
-func (s *Server) Run() {
-	appPort := os.Getenv("PORT")
-	if appPort == "" {
-		appPort = "8080"
+// ドメインイベントの配信先を組み立てる
+// プロセス内のバスと Webhook の配送は常に使い、ファイル / HTTP の配信先が設定されていればそれぞれ追加する
+func domainEventSinks(store *repository.Store, bus *outbox.Bus, cfg config.DomainEvents) []outbox.Sink {
+	sinks := []outbox.Sink{bus, webhook.NewSink(store)}
+	if cfg.File != "" {
+		sinks = append(sinks, outbox.NewFileSink(cfg.File))
 	}
-
-	log.Printf("Starting server on :%s", appPort)
-	if err := http.ListenAndServe(":"+appPort, s.Router); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	if cfg.HTTPURL != "" {
+		sinks = append(sinks, outbox.NewHTTPSink(cfg.HTTPURL, nil))
 	}
+	return sinks
 }
@@ -4,9 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
 	"time"
 
+	"backend/internal/logging"
 	"backend/internal/repository"
 	"backend/internal/service/utils"
 
@@ -37,7 +37,7 @@ func (s *AuthService) Login(ctx context.Context, userName, password string) (str
 	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
 		user, err := s.store.UserRepo.FindByUserName(ctx, userName)
 		if err != nil {
-			log.Printf("[Login] ユーザー検索失敗(userName: %s): %v", userName, err)
+			logging.FromContext(ctx).Info("[Login] ユーザー検索失敗", "user_name", userName, "error", err)
 			if errors.Is(err, sql.ErrNoRows) {
 				return ErrUserNotFound
 			}
@@ -46,7 +46,7 @@ func (s *AuthService) Login(ctx context.Context, userName, password string) (str
 
 		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
 		if err != nil {
-			log.Printf("[Login] パスワード検証失敗: %v", err)
+			logging.FromContext(ctx).Info("[Login] パスワード検証失敗", "user_name", userName, "error", err)
 			span.RecordError(err)
 			return ErrInvalidPassword
 		}
@@ -54,7 +54,7 @@ func (s *AuthService) Login(ctx context.Context, userName, password string) (str
 		sessionDuration := 24 * time.Hour
 		sessionID, expiresAt, err = s.store.SessionRepo.Create(ctx, user.UserID, sessionDuration)
 		if err != nil {
-			log.Printf("[Login] セッション生成失敗: %v", err)
+			logging.FromContext(ctx).Error("[Login] セッション生成失敗", "user_name", userName, "error", err)
 			return ErrInternalServer
 		}
 		return nil
@@ -62,6 +62,6 @@ func (s *AuthService) Login(ctx context.Context, userName, password string) (str
 	if err != nil {
 		return "", time.Time{}, err
 	}
-	log.Printf("Login successful for UserName '%s', session created.", userName)
+	logging.FromContext(ctx).Info("Login successful", "user_name", userName)
 	return sessionID, expiresAt, nil
 }
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+)
+
+var (
+	ErrCartItemNotFound = errors.New("cart item not found")
+	ErrCartEmpty        = errors.New("cart is empty")
+)
+
+type CartService struct {
+	store          *repository.Store
+	productService *ProductService
+	orderCache     *OrderCache
+}
+
+func NewCartService(store *repository.Store, productService *ProductService, orderCache *OrderCache) *CartService {
+	return &CartService{store: store, productService: productService, orderCache: orderCache}
+}
+
+// カートの中身を現在の商品の価格・重さ付きで取得
+func (s *CartService) GetCart(ctx context.Context, userID int) (*model.Cart, error) {
+	var items []model.CartItem
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		items, err = s.store.CartRepo.List(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cart := &model.Cart{Items: items}
+	for _, item := range items {
+		cart.TotalQuantity += item.Quantity
+		cart.TotalValue += item.Value * item.Quantity
+		cart.TotalWeight += item.Weight * item.Quantity
+	}
+	return cart, nil
+}
+
+// カートに商品を追加する
+// 既にカートにある商品の場合は数量を加算する
+func (s *CartService) AddItem(ctx context.Context, userID int, item model.RequestItem) error {
+	if item.Quantity < 1 {
+		return &ValidationError{Errors: []FieldError{quantityOutOfRange("quantity")}}
+	}
+	return utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			// 同時に追加されても、種類数と数量の確認が他の追加と重ならないようにする
+			if err := txStore.CartRepo.LockUser(ctx, userID); err != nil {
+				return err
+			}
+			// 追加する数量と加算後の数量の両方が範囲内であること
+			if err := validateOrderItems(ctx, txStore, []model.RequestItem{item}); err != nil {
+				return err
+			}
+			quantity, err := txStore.CartRepo.AddQuantity(ctx, userID, item.ProductID, item.Quantity)
+			if err != nil {
+				return err
+			}
+			if quantity > maxOrderQuantity {
+				return &ValidationError{Errors: []FieldError{quantityOutOfRange("quantity")}}
+			}
+			count, err := txStore.CartRepo.CountItems(ctx, userID)
+			if err != nil {
+				return err
+			}
+			if count > maxOrderItems {
+				return &ValidationError{Errors: []FieldError{{
+					Field:   "items",
+					Code:    ValidationCodeTooManyItems,
+					Message: fmt.Sprintf("at most %d items can be ordered at once", maxOrderItems),
+				}}}
+			}
+			return nil
+		})
+	})
+}
+
+// カート内の商品の数量を変更する
+func (s *CartService) UpdateQuantity(ctx context.Context, userID int, item model.RequestItem) error {
+	return utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			current, err := txStore.CartRepo.GetQuantityForUpdate(ctx, userID, item.ProductID)
+			if err != nil {
+				return err
+			}
+			if current == 0 {
+				return ErrCartItemNotFound
+			}
+			if err := validateOrderItems(ctx, txStore, []model.RequestItem{item}); err != nil {
+				return err
+			}
+			return txStore.CartRepo.Upsert(ctx, userID, item.ProductID, item.Quantity)
+		})
+	})
+}
+
+// カートから商品を削除する
+func (s *CartService) RemoveItem(ctx context.Context, userID, productID int) error {
+	return utils.WithTimeout(ctx, func(ctx context.Context) error {
+		removed, err := s.store.CartRepo.Remove(ctx, userID, productID)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return ErrCartItemNotFound
+		}
+		return nil
+	})
+}
+
+// カートの中身を注文し、カートを空にする
+// 注文の作成とカートの削除は1つのトランザクションで行う
+func (s *CartService) Checkout(ctx context.Context, userID int) (*CreateOrdersResult, error) {
+	var result *CreateOrdersResult
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			// 注文の作成でもユーザーの行を参照するため、追加と同じ順にロックする
+			if err := txStore.CartRepo.LockUser(ctx, userID); err != nil {
+				return err
+			}
+			items, err := txStore.CartRepo.ListForCheckout(ctx, userID)
+			if err != nil {
+				return err
+			}
+			if len(items) == 0 {
+				return ErrCartEmpty
+			}
+			result, err = s.productService.CreateOrdersTx(ctx, txStore, userID, items)
+			if err != nil {
+				return err
+			}
+			return txStore.CartRepo.Clear(ctx, userID)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.orderCache.InvalidateUsers(userID)
+
+	logging.FromContext(ctx).Info("Checked out cart", "user_id", userID, "orders", len(result.OrderIDs))
+	return result, nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"backend/internal/dbtest"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func newTestCartService(t *testing.T) (*CartService, *sqlx.DB) {
+	t.Helper()
+	db := dbtest.Open(t)
+	store := repository.NewStore(db)
+	cache := NewOrderCache(100, time.Second)
+	return NewCartService(store, NewProductService(store, cache), cache), db
+}
+
+func TestAddItemConcurrentlyAddsUp(t *testing.T) {
+	svc, db := newTestCartService(t)
+	userID := dbtest.CreateUser(t, db, "user")
+	productID := dbtest.CreateProduct(t, db, "product", 1)
+
+	// カートにない商品を同時に追加しても、数量がすべて加算される
+	const n = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- svc.AddItem(context.Background(), userID, model.RequestItem{ProductID: productID, Quantity: 2})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cart, err := svc.GetCart(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cart.Items) != 1 || cart.TotalQuantity != 2*n {
+		t.Fatalf("cart = %d items, quantity %d, want 1 item, quantity %d", len(cart.Items), cart.TotalQuantity, 2*n)
+	}
+}
+
+func TestAddItemLimits(t *testing.T) {
+	svc, db := newTestCartService(t)
+	ctx := context.Background()
+	userID := dbtest.CreateUser(t, db, "user")
+	productIDs := make([]int, maxOrderItems+1)
+	for i := range productIDs {
+		productIDs[i] = dbtest.CreateProduct(t, db, "product"+strconv.Itoa(i), 1)
+	}
+
+	for _, id := range productIDs[:maxOrderItems] {
+		if err := svc.AddItem(ctx, userID, model.RequestItem{ProductID: id, Quantity: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name string
+		item model.RequestItem
+		code string
+	}{
+		{"too many items", model.RequestItem{ProductID: productIDs[maxOrderItems], Quantity: 1}, ValidationCodeTooManyItems},
+		{"quantity over the limit", model.RequestItem{ProductID: productIDs[0], Quantity: maxOrderQuantity}, ValidationCodeQuantityOutOfRange},
+		{"zero quantity", model.RequestItem{ProductID: productIDs[0], Quantity: 0}, ValidationCodeQuantityOutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.AddItem(ctx, userID, tt.item)
+			var verr *ValidationError
+			if !errors.As(err, &verr) || verr.Errors[0].Code != tt.code {
+				t.Fatalf("err = %v, want %s", err, tt.code)
+			}
+		})
+	}
+
+	// 上限を超えた追加は取り消されている
+	cart, err := svc.GetCart(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cart.Items) != maxOrderItems || cart.TotalQuantity != maxOrderItems {
+		t.Errorf("cart = %d items, quantity %d, want %d and %d", len(cart.Items), cart.TotalQuantity, maxOrderItems, maxOrderItems)
+	}
+}
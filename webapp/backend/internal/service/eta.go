@@ -0,0 +1,430 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+const (
+	// 推定に使う情報を読み直す間隔
+	etaSnapshotTTL = 30 * time.Second
+	// 所要時間の実績として読む配達済みの注文の件数
+	etaHistorySize = 2000
+	// 配達のペース（件/秒）を測る期間と、測定に必要な最小件数
+	etaThroughputWindow  = time.Hour
+	etaMinRecentArrivals = 10
+	// 精度の検証に使う注文の最大件数
+	maxETAAccuracySamples = 1000
+	// 推定に使う情報の読み込みにかける時間の上限
+	etaSnapshotLoadTimeout = 30 * time.Second
+)
+
+// 配達予定時刻の推定器
+//
+// 配送待ち(shipping)の注文は、配送計画が価値の高い注文を優先して選ぶことから、
+// 価値密度（価値/重さ）の高い順に並べた配送待ちの列での位置と、直近の配達のペースから待ち時間を求める。
+// 直近の配達が少なくペースを測れない場合は、配送待ちの件数と過去の所要時間からリトルの法則で求める。
+// 配送中(delivering)の注文は、引き受けからの経過時間と過去の引き受け〜配達の所要時間から求める。
+// 推定の幅は、過去の作成〜到着の所要時間のばらつき（四分位）から付ける
+type ETAEstimator struct {
+	store *repository.Store
+
+	mu   sync.Mutex
+	snap *etaSnapshot
+	// 読み込み中の場合、読み込みが終わると閉じられる
+	loading chan struct{}
+	loadErr error
+}
+
+func NewETAEstimator(store *repository.Store) *ETAEstimator {
+	return &ETAEstimator{store: store}
+}
+
+// 推定に使う情報
+type etaSnapshot struct {
+	takenAt time.Time
+	// 作成〜到着の所要時間の実績（秒、昇順）
+	durations []float64
+	// 配達のペース（件/秒）。0 の場合は不明
+	throughput float64
+	// 配送待ちの注文の、先に配送されるはずの注文の件数
+	ahead   map[int64]int
+	backlog int
+	// 引き受け〜配達の所要時間の中央値（秒）
+	transit float64
+	// 配送中の注文を引き受けた日時
+	claimedAt map[int64]time.Time
+}
+
+// 注文に配達予定時刻を付ける
+// 読み込み済みの推定情報だけを使い、DB にはアクセスしない。
+// 推定情報をまだ読み込めていない場合は読み込みを始め、付けずに返す
+func (e *ETAEstimator) Annotate(ctx context.Context, orders []model.Order) {
+	active := false
+	for _, o := range orders {
+		if o.ShippedStatus == model.StatusShipping || o.ShippedStatus == model.StatusDelivering {
+			active = true
+			break
+		}
+	}
+	if !active {
+		return
+	}
+
+	snap, err := e.snapshot(ctx, false)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to load delivery ETA inputs", "error", err)
+		return
+	}
+	if snap == nil {
+		return
+	}
+
+	now := time.Now()
+	for i := range orders {
+		o := &orders[i]
+		switch o.ShippedStatus {
+		case model.StatusShipping:
+			ahead, ok := snap.ahead[o.OrderID]
+			if !ok {
+				// 推定情報の取得後に作成された注文は列の最後にいるものとする
+				ahead = snap.backlog
+			}
+			o.ETA = estimateQueuedETA(snap, ahead, o.CreatedAt, now)
+		case model.StatusDelivering:
+			claimedAt, ok := snap.claimedAt[o.OrderID]
+			if !ok {
+				// 推定情報の取得後に引き受けられた注文は取得した時点で引き受けたものとする
+				claimedAt = snap.takenAt
+			}
+			o.ETA = estimateDeliveringETA(snap, claimedAt, o.CreatedAt, now)
+		}
+	}
+}
+
+// 配達済みの注文に対して、作成時点で推定していた場合の誤差を集計する
+// 直近に配達された注文から最大 n 件を、作成時点までに分かっていた実績と配送待ちの列だけで推定し直す
+func (e *ETAEstimator) MeasureAccuracy(ctx context.Context, n int) (*model.ETAAccuracy, error) {
+	if n <= 0 || n > maxETAAccuracySamples {
+		n = maxETAAccuracySamples
+	}
+	current, err := e.snapshot(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := e.store.OrderRepo.ListDeliverySamples(ctx, etaHistorySize)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return summarizeETAErrors(nil, 0), nil
+	}
+	// 各注文の作成時点の配送待ちの列は、検証する期間に未配達だった注文をまとめて読んで求める
+	from, to := samples[0].CreatedAt, samples[0].CreatedAt
+	for _, s := range samples {
+		if s.CreatedAt.Before(from) {
+			from = s.CreatedAt
+		}
+		if s.CreatedAt.After(to) {
+			to = s.CreatedAt
+		}
+	}
+	backlogOrders, err := e.store.OrderRepo.ListBacklogBetween(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []float64
+	within := 0
+	for _, s := range samples {
+		if len(errs) >= n {
+			break
+		}
+		at := s.CreatedAt
+
+		// 作成時点までに分かっていた実績
+		var durations []float64
+		recent := 0
+		for _, h := range samples {
+			if !h.ArrivedAt.Before(at) {
+				continue
+			}
+			durations = append(durations, h.ArrivedAt.Sub(h.CreatedAt).Seconds())
+			if !h.ArrivedAt.Before(at.Add(-etaThroughputWindow)) {
+				recent++
+			}
+		}
+		sort.Float64s(durations)
+
+		ahead, backlog := backlogAt(backlogOrders, s, at)
+		snap := &etaSnapshot{
+			durations:  durations,
+			throughput: etaThroughput(recent, backlog, durations),
+			backlog:    backlog,
+			// 過去の引き受け〜配達の所要時間は残っていないため、現在の値で代用する
+			transit: current.transit,
+		}
+		eta := estimateQueuedETA(snap, ahead, at, at)
+		if eta == nil {
+			continue
+		}
+		errs = append(errs, eta.EstimatedArrival.Sub(s.ArrivedAt).Seconds())
+		if !s.ArrivedAt.Before(eta.Earliest) && !s.ArrivedAt.After(eta.Latest) {
+			within++
+		}
+	}
+	return summarizeETAErrors(errs, within), nil
+}
+
+// 時刻 at の時点で未配達だった注文の件数と、そのうち価値密度（価値/重さ）が
+// s より高く先に配送されたはずの注文の件数を返す（orders は作成日時の古い順）
+func backlogAt(orders []model.BacklogOrder, s model.DeliverySample, at time.Time) (ahead, total int) {
+	for _, o := range orders {
+		if o.CreatedAt.After(at) {
+			break
+		}
+		if o.OrderID == s.OrderID || (o.ArrivedAt.Valid && !o.ArrivedAt.Time.After(at)) {
+			continue
+		}
+		total++
+		if lhs, rhs := o.Value*s.Weight, s.Value*o.Weight; lhs > rhs || (lhs == rhs && o.OrderID < s.OrderID) {
+			ahead++
+		}
+	}
+	return ahead, total
+}
+
+// 推定に使う情報を返す
+// 期限が切れていれば読み込みを1つだけ始め、読み込み中は古い情報を返す。
+// まだ一度も読み込んでいない場合、wait が true なら読み込みを待ち、false なら nil を返す
+func (e *ETAEstimator) snapshot(ctx context.Context, wait bool) (*etaSnapshot, error) {
+	e.mu.Lock()
+	snap := e.snap
+	if snap != nil && time.Since(snap.takenAt) < etaSnapshotTTL {
+		e.mu.Unlock()
+		return snap, nil
+	}
+	if e.loading == nil {
+		e.loading = make(chan struct{})
+		// 呼び出し元のリクエストが終わっても読み込みは続ける
+		go e.load(context.WithoutCancel(ctx), e.loading)
+	}
+	loading := e.loading
+	e.mu.Unlock()
+
+	if snap != nil || !wait {
+		return snap, nil
+	}
+	select {
+	case <-loading:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.snap == nil {
+		return nil, e.loadErr
+	}
+	return e.snap, nil
+}
+
+func (e *ETAEstimator) load(ctx context.Context, done chan struct{}) {
+	ctx, cancel := context.WithTimeout(ctx, etaSnapshotLoadTimeout)
+	defer cancel()
+	snap, err := e.loadSnapshot(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to refresh delivery ETA inputs", "error", err)
+	}
+
+	e.mu.Lock()
+	if err == nil {
+		e.snap = snap
+	}
+	e.loadErr = err
+	e.loading = nil
+	e.mu.Unlock()
+	close(done)
+}
+
+func (e *ETAEstimator) loadSnapshot(ctx context.Context) (*etaSnapshot, error) {
+	now := time.Now()
+
+	samples, err := e.store.OrderRepo.ListDeliverySamples(ctx, etaHistorySize)
+	if err != nil {
+		return nil, err
+	}
+	durations := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		if d := s.ArrivedAt.Sub(s.CreatedAt).Seconds(); d >= 0 {
+			durations = append(durations, d)
+		}
+	}
+	sort.Float64s(durations)
+
+	recent, err := e.store.OrderRepo.CountArrivedSince(ctx, now.Add(-etaThroughputWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	backlog, err := e.store.OrderRepo.GetShippingOrders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 価値密度の高い順（同じ場合は古い順）に並べ、先に配送される注文の件数を求める
+	sort.SliceStable(backlog, func(i, j int) bool {
+		a, b := backlog[i], backlog[j]
+		if lhs, rhs := a.Value*b.Weight, b.Value*a.Weight; lhs != rhs {
+			return lhs > rhs
+		}
+		return a.OrderID < b.OrderID
+	})
+	ahead := make(map[int64]int, len(backlog))
+	for i, o := range backlog {
+		ahead[o.OrderID] = i
+	}
+
+	transits, err := e.store.StatusHistRepo.ListTransitDurations(ctx, etaHistorySize)
+	if err != nil {
+		return nil, err
+	}
+	transitSecs := make([]float64, len(transits))
+	for i, d := range transits {
+		transitSecs[i] = d.Seconds()
+	}
+	sort.Float64s(transitSecs)
+
+	claimedAt, err := e.store.StatusHistRepo.ListDeliveringClaimTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &etaSnapshot{
+		takenAt:    now,
+		durations:  durations,
+		throughput: etaThroughput(recent, len(backlog), durations),
+		ahead:      ahead,
+		backlog:    len(backlog),
+		transit:    quantile(transitSecs, 0.5),
+		claimedAt:  claimedAt,
+	}, nil
+}
+
+// 配達のペース（件/秒）
+// 直近の配達件数が少ない場合は、配送待ちの件数 / 所要時間の中央値（リトルの法則）で求める
+func etaThroughput(recentArrivals, backlog int, durations []float64) float64 {
+	if recentArrivals >= etaMinRecentArrivals {
+		return float64(recentArrivals) / etaThroughputWindow.Seconds()
+	}
+	if median := quantile(durations, 0.5); backlog > 0 && median > 0 {
+		return float64(backlog) / median
+	}
+	return 0
+}
+
+// 配送待ちの注文の配達予定時刻
+// ahead は先に配送されるはずの注文の件数
+func estimateQueuedETA(s *etaSnapshot, ahead int, createdAt, now time.Time) *model.DeliveryETA {
+	low, high := etaSpread(s.durations)
+	if s.throughput > 0 {
+		wait := float64(ahead+1)/s.throughput + s.transit
+		return &model.DeliveryETA{
+			EstimatedArrival: now.Add(seconds(wait)),
+			Earliest:         now.Add(seconds(wait * low)),
+			Latest:           now.Add(seconds(wait * high)),
+			Basis:            model.ETABasisBacklog,
+		}
+	}
+	return historicalETA(s, createdAt, now)
+}
+
+// 配送中の注文の配達予定時刻
+// 引き受けた日時が分からない場合は、過去の作成〜到着の所要時間から求める
+func estimateDeliveringETA(s *etaSnapshot, claimedAt, createdAt, now time.Time) *model.DeliveryETA {
+	if s.transit <= 0 || claimedAt.IsZero() {
+		return historicalETA(s, createdAt, now)
+	}
+	low, high := etaSpread(s.durations)
+	return &model.DeliveryETA{
+		EstimatedArrival: notBefore(claimedAt.Add(seconds(s.transit)), now),
+		Earliest:         notBefore(claimedAt.Add(seconds(s.transit*low)), now),
+		Latest:           notBefore(claimedAt.Add(seconds(s.transit*high)), now),
+		Basis:            model.ETABasisHistorical,
+	}
+}
+
+// 過去の作成〜到着の所要時間の四分位から求める
+// 既に過ぎている場合は現在時刻とする
+func historicalETA(s *etaSnapshot, createdAt, now time.Time) *model.DeliveryETA {
+	if len(s.durations) == 0 {
+		return nil
+	}
+	return &model.DeliveryETA{
+		EstimatedArrival: notBefore(createdAt.Add(seconds(quantile(s.durations, 0.5))), now),
+		Earliest:         notBefore(createdAt.Add(seconds(quantile(s.durations, 0.25))), now),
+		Latest:           notBefore(createdAt.Add(seconds(quantile(s.durations, 0.75))), now),
+		Basis:            model.ETABasisHistorical,
+	}
+}
+
+// 推定の幅（中央値に対する第1・第3四分位の比）
+func etaSpread(durations []float64) (low, high float64) {
+	median := quantile(durations, 0.5)
+	if median <= 0 {
+		return 0.75, 1.5
+	}
+	return quantile(durations, 0.25) / median, quantile(durations, 0.75) / median
+}
+
+// 誤差（推定 - 実績、秒）を集計する
+func summarizeETAErrors(errs []float64, within int) *model.ETAAccuracy {
+	acc := &model.ETAAccuracy{Samples: len(errs)}
+	if len(errs) == 0 {
+		return acc
+	}
+	abs := make([]float64, len(errs))
+	var sum, sumAbs float64
+	for i, e := range errs {
+		sum += e
+		abs[i] = math.Abs(e)
+		sumAbs += abs[i]
+	}
+	sort.Float64s(abs)
+	acc.MeanErrorSec = sum / float64(len(errs))
+	acc.MeanAbsErrorSec = sumAbs / float64(len(errs))
+	acc.MedianAbsErrorSec = quantile(abs, 0.5)
+	acc.P90AbsErrorSec = quantile(abs, 0.9)
+	acc.WithinRangeRate = float64(within) / float64(len(errs))
+	return acc
+}
+
+// 昇順に並んだ値の分位点（線形補間）
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	frac := pos - float64(i)
+	return sorted[i] + (sorted[i+1]-sorted[i])*frac
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func notBefore(t, min time.Time) time.Time {
+	if t.Before(min) {
+		return min
+	}
+	return t
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"backend/internal/dbtest"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestMeasureAccuracyOnSteadyDeliveries(t *testing.T) {
+	db := dbtest.Open(t)
+	userID := dbtest.CreateUser(t, db, "user")
+	productID := dbtest.CreateProduct(t, db, "product", 1)
+
+	// 1分ごとに注文が入り、約10分（9〜11分）で配達される状態が続いていた
+	base := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	const orders = 200
+	for i := 0; i < orders; i++ {
+		createdAt := base.Add(time.Duration(i) * time.Minute)
+		orderID := dbtest.CreateOrder(t, db, userID, productID, model.StatusCompleted, createdAt)
+		took := 10*time.Minute + time.Duration(i%3-1)*time.Minute
+		if _, err := db.Exec("UPDATE orders SET arrived_at = ? WHERE order_id = ?", createdAt.Add(took), orderID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const n = 50
+	acc, err := NewETAEstimator(repository.NewStore(db)).MeasureAccuracy(context.Background(), n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Samples != n {
+		t.Fatalf("samples = %d, want %d", acc.Samples, n)
+	}
+	// 配送待ちは常に約10件、ペースは1件/分なので、推定は所要時間のばらつき（±1分）程度しか外れない
+	if acc.MeanAbsErrorSec > 60 || acc.P90AbsErrorSec > 90 {
+		t.Errorf("mean abs error = %.0fs, p90 = %.0fs, want within the ±60s spread of delivery times", acc.MeanAbsErrorSec, acc.P90AbsErrorSec)
+	}
+	if acc.MeanErrorSec < -30 || acc.MeanErrorSec > 30 {
+		t.Errorf("mean error = %.0fs, want no bias", acc.MeanErrorSec)
+	}
+	if acc.WithinRangeRate < 0.6 {
+		t.Errorf("within range rate = %.2f, want most arrivals inside the estimated range", acc.WithinRangeRate)
+	}
+}
+
+func TestSnapshotServesStaleWhileRefreshing(t *testing.T) {
+	// 読み込みは必ず失敗する
+	db := sqlx.NewDb(sql.OpenDB(failingConnector{}), "mysql")
+	e := NewETAEstimator(repository.NewStore(db))
+	stale := &etaSnapshot{takenAt: time.Now().Add(-time.Hour), backlog: 3}
+	e.snap = stale
+
+	got, err := e.snapshot(context.Background(), false)
+	if err != nil || got != stale {
+		t.Fatalf("snapshot() = %v, %v; want the stale snapshot without waiting", got, err)
+	}
+	waitLoaded(t, e)
+	if e.snap != stale {
+		t.Fatal("stale snapshot was replaced by a failed load")
+	}
+
+	// 一度も読み込めていない場合、待たなければ何も返さない
+	e.snap = nil
+	if got, err := e.snapshot(context.Background(), false); got != nil || err != nil {
+		t.Fatalf("snapshot(wait=false) = %v, %v; want nil without waiting", got, err)
+	}
+	waitLoaded(t, e)
+	// 待つ場合は読み込みを待ってエラーを返す
+	if _, err := e.snapshot(context.Background(), true); err == nil {
+		t.Fatal("snapshot() without a loaded snapshot: want the load error")
+	}
+}
+
+func TestAnnotateUsesLoadedClaimTimes(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	store := repository.NewStore(db)
+	userID := dbtest.CreateUser(t, db, "user")
+	productID := dbtest.CreateProduct(t, db, "product", 1)
+
+	// 引き受けから10分で配達された実績と、3分前に引き受けられた配送中の注文
+	now := time.Now().Truncate(time.Second)
+	done := dbtest.CreateOrder(t, db, userID, productID, model.StatusCompleted, now.Add(-time.Hour))
+	delivering := dbtest.CreateOrder(t, db, userID, productID, model.StatusDelivering, now.Add(-10*time.Minute))
+	err := store.StatusHistRepo.Record(ctx, []model.OrderStatusHistory{
+		{OrderID: done, NewStatus: model.StatusDelivering, Actor: "robot", ChangedAt: now.Add(-50 * time.Minute)},
+		{OrderID: done, NewStatus: model.StatusCompleted, Actor: "robot", ChangedAt: now.Add(-40 * time.Minute)},
+		{OrderID: delivering, NewStatus: model.StatusDelivering, Actor: "robot", ChangedAt: now.Add(-3 * time.Minute)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewETAEstimator(store)
+	if _, err := e.snapshot(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	// 読み込み後は DB にアクセスせずに推定する
+	e.store = repository.NewStore(sqlx.NewDb(sql.OpenDB(failingConnector{}), "mysql"))
+	orders := []model.Order{{OrderID: delivering, ShippedStatus: model.StatusDelivering, CreatedAt: now.Add(-10 * time.Minute)}}
+	e.Annotate(ctx, orders)
+	if orders[0].ETA == nil {
+		t.Fatal("ETA was not set")
+	}
+	if want := now.Add(7 * time.Minute); !orders[0].ETA.EstimatedArrival.Equal(want) {
+		t.Errorf("estimated arrival = %s, want %s", orders[0].ETA.EstimatedArrival, want)
+	}
+}
+
+func TestBacklogAt(t *testing.T) {
+	at := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	arrived := func(d time.Duration) sql.NullTime { return sql.NullTime{Time: at.Add(d), Valid: true} }
+	orders := []model.BacklogOrder{
+		{OrderID: 1, CreatedAt: at.Add(-3 * time.Hour), ArrivedAt: arrived(-time.Hour), Value: 100, Weight: 1}, // 配達済み
+		{OrderID: 2, CreatedAt: at.Add(-2 * time.Hour), ArrivedAt: arrived(time.Hour), Value: 100, Weight: 1},  // 先
+		{OrderID: 3, CreatedAt: at.Add(-time.Hour), Value: 10, Weight: 1},                                      // 後
+		{OrderID: 4, CreatedAt: at.Add(-time.Minute), Value: 50, Weight: 1},                                    // 本人
+		{OrderID: 5, CreatedAt: at, Value: 50, Weight: 1},                                                      // 同じ価値密度で ID が後
+		{OrderID: 6, CreatedAt: at.Add(time.Minute), Value: 100, Weight: 1},                                    // まだ作成されていない
+	}
+	ahead, total := backlogAt(orders, model.DeliverySample{OrderID: 4, Value: 50, Weight: 1}, at)
+	if ahead != 1 || total != 3 {
+		t.Fatalf("backlogAt() = %d, %d; want 1 ahead of 3", ahead, total)
+	}
+}
+
+func waitLoaded(t *testing.T, e *ETAEstimator) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		e.mu.Lock()
+		loading := e.loading
+		e.mu.Unlock()
+		if loading == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("snapshot load did not finish")
+		}
+		<-loading
+	}
+}
+
+type failingConnector struct{}
+
+func (failingConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("database is unavailable")
+}
+
+func (failingConnector) Driver() driver.Driver { return nil }
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/logging"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	HealthOK   = "ok"
+	HealthFail = "fail"
+)
+
+// 依存先ごとの状態
+type DependencyHealth struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	// 認証なしで返すため、接続先などを含む元のエラーは載せずにログに出す
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+type HealthReport struct {
+	Status string                      `json:"status"`
+	Checks map[string]DependencyHealth `json:"checks"`
+}
+
+// DB の接続プールの状態
+type DBPoolHealth struct {
+	MaxOpen   int   `json:"max_open"`
+	Open      int   `json:"open"`
+	InUse     int   `json:"in_use"`
+	Idle      int   `json:"idle"`
+	WaitCount int64 `json:"wait_count"`
+}
+
+// readiness の判定に使う依存先の確認
+type HealthService struct {
+	db          *sqlx.DB
+	pingTimeout time.Duration
+	// 前回の確認時点の、接続の空き待ちの累計回数
+	lastWaitCount atomic.Int64
+}
+
+func NewHealthService(db *sqlx.DB, pingTimeout time.Duration) *HealthService {
+	return &HealthService{db: db, pingTimeout: pingTimeout}
+}
+
+// 依存先を確認する
+func (s *HealthService) Check(ctx context.Context) HealthReport {
+	report := HealthReport{Status: HealthOK, Checks: map[string]DependencyHealth{}}
+	db := s.checkDB(ctx)
+	report.Checks["database"] = db
+	if db.Status != HealthOK {
+		report.Status = HealthFail
+	}
+	return report
+}
+
+// DB に ping し、接続プールが使い切られていないか確認する
+// 接続がすべて使用中で、前回の確認以降に空き待ちが発生している場合は使い切られているとみなす
+func (s *HealthService) checkDB(ctx context.Context) DependencyHealth {
+	stats := s.db.Stats()
+	pool := DBPoolHealth{
+		MaxOpen:   stats.MaxOpenConnections,
+		Open:      stats.OpenConnections,
+		InUse:     stats.InUse,
+		Idle:      stats.Idle,
+		WaitCount: stats.WaitCount,
+	}
+	prevWaits := s.lastWaitCount.Swap(stats.WaitCount)
+	exhausted := stats.MaxOpenConnections > 0 &&
+		stats.InUse >= stats.MaxOpenConnections &&
+		stats.WaitCount > prevWaits
+
+	ctx, cancel := context.WithTimeout(ctx, s.pingTimeout)
+	defer cancel()
+	start := time.Now()
+	err := s.db.PingContext(ctx)
+	h := DependencyHealth{
+		Status:    HealthOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   pool,
+	}
+	switch {
+	case err != nil:
+		h.Status = HealthFail
+		h.Error = "database ping failed"
+		if errors.Is(err, context.DeadlineExceeded) {
+			h.Error = "database ping timed out"
+		}
+		logging.FromContext(ctx).Warn("Database health check failed", "error", err)
+	case exhausted:
+		h.Status = HealthFail
+		h.Error = fmt.Sprintf("connection pool exhausted (%d/%d in use, %d waits since last check)",
+			stats.InUse, stats.MaxOpenConnections, stats.WaitCount-prevWaits)
+	}
+	return h
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestCheckHidesDriverError(t *testing.T) {
+	db := sqlx.NewDb(sql.OpenDB(failingConnector{}), "mysql")
+	report := NewHealthService(db, time.Second).Check(context.Background())
+
+	got := report.Checks["database"]
+	if report.Status != HealthFail || got.Status != HealthFail {
+		t.Fatalf("Check() = %+v, want fail", report)
+	}
+	if got.Error != "database ping failed" || strings.Contains(got.Error, "unavailable") {
+		t.Errorf("error = %q, want the generic message without the driver error", got.Error)
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"strconv"
+	"time"
+
+	"backend/internal/events"
+	"backend/internal/model"
+)
+
+// ステータス変更履歴に記録する変更主体
+func userActor(userID int) string {
+	return "user:" + strconv.Itoa(userID)
+}
+
+func robotActor(robotID string) string {
+	if robotID == "" {
+		return "robot"
+	}
+	return "robot:" + robotID
+}
+
+// 同じ変更を複数の注文に対して記録するための履歴を作る
+// oldStatus が空文字の場合は注文作成として記録する
+func statusChanges(orderIDs []int64, oldStatus, newStatus, actor string) []model.OrderStatusHistory {
+	var old *string
+	if oldStatus != "" {
+		old = &oldStatus
+	}
+	now := time.Now()
+	entries := make([]model.OrderStatusHistory, len(orderIDs))
+	for i, id := range orderIDs {
+		entries[i] = model.OrderStatusHistory{
+			OrderID:   id,
+			OldStatus: old,
+			NewStatus: newStatus,
+			Actor:     actor,
+			ChangedAt: now,
+		}
+	}
+	return entries
+}
+
+// ステータス変更履歴から通知用のイベントを作る
+func statusEvents(history []model.OrderStatusHistory, owners map[int64]int) []events.OrderStatusEvent {
+	evs := make([]events.OrderStatusEvent, 0, len(history))
+	for _, h := range history {
+		userID, ok := owners[h.OrderID]
+		if !ok {
+			continue
+		}
+		ev := events.OrderStatusEvent{
+			UserID:    userID,
+			OrderID:   h.OrderID,
+			NewStatus: h.NewStatus,
+			ChangedAt: h.ChangedAt,
+		}
+		if h.OldStatus != nil {
+			ev.OldStatus = *h.OldStatus
+		}
+		evs = append(evs, ev)
+	}
+	return evs
+}
+
+// コミット済みのステータス変更を反映する
+// 対象ユーザーの注文履歴キャッシュを破棄し、購読中の接続へ通知する
+func notifyStatusChanges(cache *OrderCache, hub *events.Hub, evs []events.OrderStatusEvent) {
+	if len(evs) == 0 {
+		return
+	}
+	seen := make(map[int]bool)
+	var userIDs []int
+	for _, ev := range evs {
+		if !seen[ev.UserID] {
+			seen[ev.UserID] = true
+			userIDs = append(userIDs, ev.UserID)
+		}
+	}
+	cache.InvalidateUsers(userIDs...)
+	hub.Publish(evs...)
+}
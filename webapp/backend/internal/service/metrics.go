@@ -0,0 +1,27 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	deliveryPlanCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "delivery_plan_cache_requests_total",
+		Help: "Delivery plan cache lookups by result (hit or miss).",
+	}, []string{"result"})
+	deliveryPlanDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "delivery_planner_duration_seconds",
+		Help:    "Time to build and claim a delivery plan, including retries.",
+		Buckets: prometheus.DefBuckets,
+	})
+	deliveryPlanBacklog = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "delivery_planner_backlog_orders",
+		Help: "Shipping orders considered by the most recent delivery plan.",
+	})
+	deliveryPlanOrders = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "delivery_plan_orders",
+		Help:    "Orders included in each delivery plan.",
+		Buckets: []float64{0, 1, 2, 5, 10, 20, 50, 100},
+	})
+)
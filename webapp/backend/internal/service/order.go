@@ -1,40 +1,45 @@
 package service
 
 import (
+	"backend/internal/events"
 	"backend/internal/model"
 	"backend/internal/repository"
 	"backend/internal/service/utils"
 	"context"
 	"crypto/sha1"
+	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"sync"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
+var (
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrOrderGroupNotFound  = errors.New("order group not found")
+	ErrOrderNotCancellable = errors.New("order can no longer be cancelled")
+	ErrOrderCancelled      = errors.New("order has been cancelled")
+)
+
 type OrderService struct {
 	store *repository.Store
-}
-
-// --- キャッシュ構造 ---
-type cachedOrders struct {
-	orders    []model.Order
-	total     int
-	expiresAt time.Time
-}
-
-var ordersCache struct {
-	sync.RWMutex
-	data map[string]cachedOrders
-}
-
-func init() {
-	ordersCache.data = make(map[string]cachedOrders)
+	cache *OrderCache
+	hub   *events.Hub
+	eta   *ETAEstimator
 }
 
 // キャッシュキー生成
 func makeCacheKey(userID int, req model.ListRequest) string {
-	raw := fmt.Sprintf("%d|%s|%s|%s|%s|%d|%d",
+	statuses := append([]string(nil), req.ShippedStatuses...)
+	sort.Strings(statuses)
+	productID := "-"
+	if req.ProductID != nil {
+		productID = strconv.Itoa(*req.ProductID)
+	}
+	raw := fmt.Sprintf("%d|%s|%s|%s|%s|%d|%d|%s|%s|%s|%s|%s|%s",
 		userID,
 		req.SortField,
 		req.SortOrder,
@@ -42,13 +47,37 @@ func makeCacheKey(userID int, req model.ListRequest) string {
 		req.Type,
 		req.PageSize,
 		req.Offset,
+		strings.Join(statuses, ","),
+		productID,
+		cacheKeyTime(req.CreatedFrom),
+		cacheKeyTime(req.CreatedTo),
+		cacheKeyTime(req.ArrivedFrom),
+		cacheKeyTime(req.ArrivedTo),
 	)
 	h := sha1.Sum([]byte(raw))
 	return hex.EncodeToString(h[:])
 }
 
-func NewOrderService(store *repository.Store) *OrderService {
-	return &OrderService{store: store}
+func cacheKeyTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func NewOrderService(store *repository.Store, cache *OrderCache, hub *events.Hub, eta *ETAEstimator) *OrderService {
+	return &OrderService{store: store, cache: cache, hub: hub, eta: eta}
+}
+
+// ユーザーの注文ステータス変更を購読する
+// lastEventID より後の取りこぼしたイベントを併せて返す
+func (s *OrderService) SubscribeStatusEvents(userID int, lastEventID uint64) (*events.Subscription, []events.OrderStatusEvent) {
+	return s.hub.Subscribe(userID, lastEventID)
+}
+
+// 注文履歴キャッシュの統計情報を取得
+func (s *OrderService) CacheStats() OrderCacheStats {
+	return s.cache.Stats()
 }
 
 // ユーザーの注文履歴を取得
@@ -56,16 +85,11 @@ func (s *OrderService) FetchOrders(ctx context.Context, userID int, req model.Li
 	key := makeCacheKey(userID, req)
 
 	// --- キャッシュ確認 ---
-	ordersCache.RLock()
-	if cp, ok := ordersCache.data[key]; ok && time.Now().Before(cp.expiresAt) {
-		// コピーを返す（外側で変更されないように）
-		ordersCopy := make([]model.Order, len(cp.orders))
-		copy(ordersCopy, cp.orders)
-		total := cp.total
-		ordersCache.RUnlock()
-		return ordersCopy, total, nil
+	cached, cachedTotal, gen, ok := s.cache.Get(userID, key)
+	if ok {
+		s.eta.Annotate(ctx, cached)
+		return cached, cachedTotal, nil
 	}
-	ordersCache.RUnlock()
 
 	// --- DBアクセス ---
 	var orders []model.Order
@@ -76,17 +100,156 @@ func (s *OrderService) FetchOrders(ctx context.Context, userID int, req model.Li
 		return fetchErr
 	})
 	if err != nil {
+		s.cache.Release(userID)
 		return nil, 0, err
 	}
 
 	// --- キャッシュ保存 ---
-	ordersCache.Lock()
-	ordersCache.data[key] = cachedOrders{
-		orders:    orders,
-		total:     total,
-		expiresAt: time.Now().Add(1 * time.Second), // キャッシュTTL
+	s.cache.Set(userID, key, gen, orders, total)
+
+	// 配達予定時刻は時間とともに変わるため、キャッシュとは別のコピーに付ける
+	result := make([]model.Order, len(orders))
+	copy(result, orders)
+	s.eta.Annotate(ctx, result)
+
+	return result, total, nil
+}
+
+// 過去の注文に対する配達予定時刻の推定精度を取得
+func (s *OrderService) ETAAccuracy(ctx context.Context, samples int) (*model.ETAAccuracy, error) {
+	return s.eta.MeasureAccuracy(ctx, samples)
+}
+
+// ユーザーの注文履歴を絞り込み条件に従って1件ずつ fn に渡す
+// 件数が多くなるため、utils.WithTimeout は使わずリクエストのコンテキストに従う
+func (s *OrderService) ExportOrders(ctx context.Context, userID int, req model.ListRequest, fn func(model.Order) error) error {
+	return s.store.OrderRepo.StreamOrders(ctx, userID, req, fn)
+}
+
+// ユーザーの注文をキャンセルする
+// 配送ロボットに引き受けられる前(shipping)の注文のみキャンセルできる
+func (s *OrderService) CancelOrder(ctx context.Context, userID int, orderID int64) error {
+	var changes []events.OrderStatusEvent
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			cancelled, err := txStore.OrderRepo.Cancel(ctx, userID, orderID)
+			if err != nil {
+				return err
+			}
+			if cancelled {
+				history := statusChanges([]int64{orderID}, model.StatusShipping, model.StatusCancelled, userActor(userID))
+				changes = statusEvents(history, map[int64]int{orderID: userID})
+				return txStore.StatusHistRepo.Record(ctx, history)
+			}
+
+			// 更新できなかった理由を判別する
+			if _, err := txStore.OrderRepo.FindByIDForUser(ctx, userID, orderID); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return ErrOrderNotFound
+				}
+				return err
+			}
+			return ErrOrderNotCancellable
+		})
+	})
+	if err != nil {
+		return err
 	}
-	ordersCache.Unlock()
+	notifyStatusChanges(s.cache, s.hub, changes)
+	return nil
+}
+
+// ユーザーの注文をステータス変更履歴付きで取得
+func (s *OrderService) GetOrderDetail(ctx context.Context, userID int, orderID int64) (*model.OrderDetail, error) {
+	var detail model.OrderDetail
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		order, err := s.store.OrderRepo.FindByIDForUser(ctx, userID, orderID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+		history, err := s.store.StatusHistRepo.ListByOrderID(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		orders := []model.Order{*order}
+		s.eta.Annotate(ctx, orders)
+		detail = model.OrderDetail{Order: orders[0], History: history}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &detail, nil
+}
 
-	return orders, total, nil
+// ユーザーの注文グループを商品ごとの内訳と進捗付きで取得
+func (s *OrderService) FetchOrderGroups(ctx context.Context, userID int, req model.ListRequest) ([]model.OrderGroup, int, error) {
+	var groups []model.OrderGroup
+	var total int
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		groups, total, err = s.store.OrderGroupRepo.List(ctx, userID, req.PageSize, req.Offset)
+		if err != nil {
+			return err
+		}
+		return s.fillOrderGroups(ctx, groups)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return groups, total, nil
+}
+
+// ユーザーの注文グループを1件取得
+func (s *OrderService) GetOrderGroup(ctx context.Context, userID int, groupID int64) (*model.OrderGroup, error) {
+	var group *model.OrderGroup
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		group, err = s.store.OrderGroupRepo.FindForUser(ctx, userID, groupID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrOrderGroupNotFound
+			}
+			return err
+		}
+		groups := []model.OrderGroup{*group}
+		if err := s.fillOrderGroups(ctx, groups); err != nil {
+			return err
+		}
+		*group = groups[0]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// 注文グループに商品ごとの内訳と進捗を詰める
+func (s *OrderService) fillOrderGroups(ctx context.Context, groups []model.OrderGroup) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	groupIDs := make([]int64, len(groups))
+	index := make(map[int64]int, len(groups))
+	for i, g := range groups {
+		groupIDs[i] = g.GroupID
+		index[g.GroupID] = i
+		groups[i].Items = []model.OrderGroupItem{}
+	}
+
+	items, err := s.store.OrderGroupRepo.ListItems(ctx, groupIDs)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		g := &groups[index[item.GroupID]]
+		g.Items = append(g.Items, item)
+		g.TotalQuantity += item.Quantity
+		g.Progress.Add(item.OrderGroupProgress)
+	}
+	return nil
 }
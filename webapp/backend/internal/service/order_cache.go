@@ -0,0 +1,208 @@
+package service
+
+import (
+	"backend/internal/model"
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOrderCacheTTL        = 1 * time.Second
+	defaultOrderCacheMaxEntries = 10000
+)
+
+// 注文履歴キャッシュの統計情報
+type OrderCacheStats struct {
+	Hits      uint64  `json:"hits"`
+	Misses    uint64  `json:"misses"`
+	Evictions uint64  `json:"evictions"`
+	Size      int     `json:"size"`
+	HitRate   float64 `json:"hit_rate"`
+}
+
+// ユーザーごとのキャッシュの状態
+type orderCacheUser struct {
+	keys map[string]struct{}
+	// 世代番号。DB読み込み中に破棄された場合に古い結果を保存しないために使う
+	generation uint64
+	// Get でヒットせず、Set / Release をまだ呼んでいない読み込みの数
+	loading int
+}
+
+type orderCacheEntry struct {
+	key       string
+	userID    int
+	orders    []model.Order
+	total     int
+	expiresAt time.Time
+}
+
+// 注文履歴のLRUキャッシュ
+// 上限件数を超えると最も古く参照されたものから破棄し、
+// 注文の作成・ステータス更新時にはユーザー単位で破棄する
+type OrderCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+	// キャッシュがあるか、DBから読み込み中のユーザーだけを持つ
+	users map[int]*orderCacheUser
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+func NewOrderCache(maxEntries int, ttl time.Duration) *OrderCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultOrderCacheMaxEntries
+	}
+	if ttl <= 0 {
+		ttl = defaultOrderCacheTTL
+	}
+	return &OrderCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		users:      make(map[int]*orderCacheUser),
+	}
+}
+
+// キャッシュを取得する
+// ヒットしなかった場合は Set に渡すための世代番号を返す
+// その場合は読み込みの結果にかかわらず Set か Release を呼ぶこと
+func (c *OrderCache) Get(userID int, key string) ([]model.Order, int, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*orderCacheEntry)
+		if time.Now().Before(e.expiresAt) {
+			c.ll.MoveToFront(el)
+			c.hits++
+			// コピーを返す（外側で変更されないように）
+			ordersCopy := make([]model.Order, len(e.orders))
+			copy(ordersCopy, e.orders)
+			return ordersCopy, e.total, 0, true
+		}
+		c.removeElement(el)
+	}
+	c.misses++
+	u := c.user(userID)
+	u.loading++
+	return nil, 0, u.generation, false
+}
+
+// Get でヒットしなかった後、DBの読み込みに失敗した場合に呼ぶ
+func (c *OrderCache) Release(userID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.endLoad(userID)
+}
+
+// キャッシュを保存する
+// Get 以降にそのユーザーのキャッシュが破棄されていた場合は保存しない
+func (c *OrderCache) Set(userID int, key string, gen uint64, orders []model.Order, total int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	u, ok := c.users[userID]
+	if !ok {
+		return
+	}
+	u.loading--
+	if u.generation != gen {
+		c.prune(userID, u)
+		return
+	}
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	e := &orderCacheEntry{
+		key:       key,
+		userID:    userID,
+		orders:    orders,
+		total:     total,
+		expiresAt: time.Now().Add(c.ttl),
+	}
+	c.items[key] = c.ll.PushFront(e)
+	u.keys[key] = struct{}{}
+
+	for c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+		c.evictions++
+	}
+}
+
+// 指定ユーザーのキャッシュをすべて破棄する
+func (c *OrderCache) InvalidateUsers(userIDs ...int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, userID := range userIDs {
+		u, ok := c.users[userID]
+		if !ok {
+			continue
+		}
+		// 読み込み中のものがあれば、世代番号を進めて結果を保存させない
+		u.generation++
+		for key := range u.keys {
+			if el, ok := c.items[key]; ok {
+				c.removeElement(el)
+			}
+		}
+	}
+}
+
+func (c *OrderCache) Stats() OrderCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := OrderCacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Size:      c.ll.Len(),
+	}
+	if total := c.hits + c.misses; total > 0 {
+		stats.HitRate = float64(c.hits) / float64(total)
+	}
+	return stats
+}
+
+func (c *OrderCache) removeElement(el *list.Element) {
+	e := c.ll.Remove(el).(*orderCacheEntry)
+	delete(c.items, e.key)
+	if u, ok := c.users[e.userID]; ok {
+		delete(u.keys, e.key)
+		c.prune(e.userID, u)
+	}
+}
+
+func (c *OrderCache) user(userID int) *orderCacheUser {
+	u, ok := c.users[userID]
+	if !ok {
+		u = &orderCacheUser{keys: make(map[string]struct{})}
+		c.users[userID] = u
+	}
+	return u
+}
+
+func (c *OrderCache) endLoad(userID int) {
+	if u, ok := c.users[userID]; ok {
+		u.loading--
+		c.prune(userID, u)
+	}
+}
+
+// キャッシュも読み込み中のものもなくなったユーザーの状態を削除する
+// 世代番号は読み込み中のものを無効にするためだけに使うので、消しても問題ない
+func (c *OrderCache) prune(userID int, u *orderCacheUser) {
+	if len(u.keys) == 0 && u.loading <= 0 {
+		delete(c.users, userID)
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"backend/internal/model"
+	"testing"
+	"time"
+)
+
+func TestOrderCacheDropsUserStateAfterEviction(t *testing.T) {
+	c := NewOrderCache(1, time.Minute)
+
+	for userID := 1; userID <= 100; userID++ {
+		_, _, gen, ok := c.Get(userID, "k")
+		if ok {
+			t.Fatalf("user %d: unexpected hit", userID)
+		}
+		c.Set(userID, "k", gen, []model.Order{{OrderID: int64(userID)}}, 1)
+		c.InvalidateUsers(userID)
+	}
+	if n := len(c.users); n != 0 {
+		t.Fatalf("users = %d, want 0 after every entry was invalidated", n)
+	}
+
+	_, _, _, _ = c.Get(7, "k")
+	c.Release(7)
+	if n := len(c.users); n != 0 {
+		t.Fatalf("users = %d, want 0 after a released load", n)
+	}
+}
+
+func TestOrderCacheSkipsSetAfterInvalidate(t *testing.T) {
+	c := NewOrderCache(10, time.Minute)
+
+	_, _, gen, _ := c.Get(1, "k")
+	// 読み込み中に注文が更新された
+	c.InvalidateUsers(1)
+	c.Set(1, "k", gen, []model.Order{{OrderID: 1}}, 1)
+
+	if _, _, _, ok := c.Get(1, "k"); ok {
+		t.Fatal("stale result was cached after invalidation")
+	}
+	c.Release(1)
+	if n := len(c.users); n != 0 {
+		t.Fatalf("users = %d, want 0", n)
+	}
+}
@@ -1,25 +1,188 @@
-
 package service
 
 import (
 	"context"
-	"log" // logパッケージをインポート
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
 
+	"backend/internal/logging"
 	"backend/internal/model"
 	"backend/internal/repository"
 )
 
+var (
+	ErrIdempotencyKeyInUse    = errors.New("idempotency key is in use by another request")
+	ErrIdempotencyKeyMismatch = errors.New("idempotency key was used with a different request")
+)
+
+var (
+	// 完了した Idempotency-Key の結果を保持する期間
+	idempotencyRetention = 24 * time.Hour
+	// 処理中のキーを放棄されたとみなすまでの時間（utils.WithTimeout の既定値に合わせる）
+	idempotencyInProgressTTL = 120 * time.Second
+	// 同じキーのリクエストが処理中の場合に完了を待つ最大時間
+	idempotencyWaitTimeout  = 5 * time.Second
+	idempotencyPollInterval = 100 * time.Millisecond
+)
+
+// 注文作成の結果
+// Replayed は Idempotency-Key により以前の結果を返した場合に true
+type CreateOrdersResult struct {
+	GroupID    int64
+	OrderIDs   []string
+	StatusCode int
+	Replayed   bool
+}
+
 type ProductService struct {
-	store *repository.Store
+	store      *repository.Store
+	orderCache *OrderCache
+}
+
+func NewProductService(store *repository.Store, orderCache *OrderCache) *ProductService {
+	return &ProductService{store: store, orderCache: orderCache}
+}
+
+// 注文を作成する
+// リクエストが不正な場合は *ValidationError を返す
+func (s *ProductService) CreateOrders(ctx context.Context, userID int, items []model.RequestItem) (*CreateOrdersResult, error) {
+	var result *CreateOrdersResult
+	err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		var err error
+		result, err = s.CreateOrdersTx(ctx, txStore, userID, items)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.orderCache.InvalidateUsers(userID)
+
+	logging.FromContext(ctx).Info("Created orders", "user_id", userID, "orders", len(result.OrderIDs))
+	return result, nil
+}
+
+// 呼び出し元のトランザクション内で注文を作成する
+// カートの購入手続きなど、他の更新と一緒に注文を作る場合に使う。
+// 注文履歴キャッシュの破棄はコミット後に呼び出し元で行うこと
+func (s *ProductService) CreateOrdersTx(ctx context.Context, txStore *repository.Store, userID int, items []model.RequestItem) (*CreateOrdersResult, error) {
+	if err := validateOrderItems(ctx, txStore, items); err != nil {
+		return nil, err
+	}
+	return createOrders(ctx, txStore, userID, items)
+}
+
+// Idempotency-Key 付きで注文を作成する
+// 同じキーで再送された場合は注文を作らず、最初のリクエストの結果を返す
+func (s *ProductService) CreateOrdersIdempotent(ctx context.Context, userID int, key string, items []model.RequestItem) (*CreateOrdersResult, error) {
+	// 不正なリクエストはキーを消費せずに返す
+	if err := validateOrderItems(ctx, s.store, items); err != nil {
+		return nil, err
+	}
+
+	requestHash, err := hashRequestItems(items)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(idempotencyWaitTimeout)
+	for {
+		err := s.store.IdempotencyRepo.Reserve(ctx, userID, key, requestHash, idempotencyInProgressTTL)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, repository.ErrDuplicateIdempotencyKey) {
+			return nil, err
+		}
+
+		rec, err := s.store.IdempotencyRepo.Find(ctx, userID, key)
+		if err != nil {
+			return nil, err
+		}
+		if rec != nil {
+			if rec.RequestHash != requestHash {
+				return nil, ErrIdempotencyKeyMismatch
+			}
+			if rec.Status == model.IdempotencyStatusCompleted {
+				return replayIdempotencyKey(rec)
+			}
+		}
+
+		// 先行リクエストが処理中なので完了を待つ
+		if time.Now().After(deadline) {
+			return nil, ErrIdempotencyKeyInUse
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+
+	var result *CreateOrdersResult
+	err = s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		var err error
+		result, err = createOrders(ctx, txStore, userID, items)
+		if err != nil {
+			return err
+		}
+		orderIDsJSON, err := json.Marshal(result.OrderIDs)
+		if err != nil {
+			return err
+		}
+		return txStore.IdempotencyRepo.Complete(ctx, userID, key, result.StatusCode, result.GroupID, string(orderIDsJSON), idempotencyRetention)
+	})
+	if err != nil {
+		// 失敗した場合はキーを解放して再試行できるようにする
+		if relErr := s.store.IdempotencyRepo.Release(context.WithoutCancel(ctx), userID, key); relErr != nil {
+			logging.FromContext(ctx).Error("Failed to release idempotency key", "user_id", userID, "error", relErr)
+		}
+		return nil, err
+	}
+	s.orderCache.InvalidateUsers(userID)
+
+	logging.FromContext(ctx).Info("Created orders", "user_id", userID, "orders", len(result.OrderIDs))
+	return result, nil
+}
+
+// 保持期間を過ぎた Idempotency-Key を定期的に削除する
+func (s *ProductService) RunIdempotencyKeyPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.store.IdempotencyRepo.DeleteExpired(ctx)
+			if err != nil {
+				logging.FromContext(ctx).Error("Failed to purge expired idempotency keys", "error", err)
+				continue
+			}
+			if n > 0 {
+				logging.FromContext(ctx).Info("Purged expired idempotency keys", "keys", n)
+			}
+		}
+	}
+}
+
+func (s *ProductService) FetchProducts(ctx context.Context, userID int, req model.ListRequest) ([]model.Product, int, error) {
+	products, total, err := s.store.ProductRepo.ListProducts(ctx, userID, req)
+	return products, total, err
 }
 
-func NewProductService(store *repository.Store) *ProductService {
-	return &ProductService{store: store}
+// 商品が参照している画像のパスを返す（画像配信の許可リストに使う）
+func (s *ProductService) ListImagePaths(ctx context.Context) ([]string, error) {
+	return s.store.ProductRepo.ListImagePaths(ctx)
 }
 
-// ★ この CreateOrders 関数をまるごと書き換える
-func (s *ProductService) CreateOrders(ctx context.Context, userID int, items []model.RequestItem) ([]string, error) {
-	
+// 注文グループを作成し、数量分だけ商品IDを展開してバルクインサートする
+// 複数の INSERT に分割されるため、トランザクション内の store を渡すこと
+func createOrders(ctx context.Context, store *repository.Store, userID int, items []model.RequestItem) (*CreateOrdersResult, error) {
 	var productIDsToOrder []int
 	for _, item := range items {
 		if item.Quantity > 0 {
@@ -28,22 +191,59 @@ func (s *ProductService) CreateOrders(ctx context.Context, userID int, items []m
 			}
 		}
 	}
-	
+
+	result := &CreateOrdersResult{OrderIDs: []string{}, StatusCode: http.StatusCreated}
 	if len(productIDsToOrder) == 0 {
-		return []string{}, nil
+		return result, nil
 	}
 
-	// repositoryに新設したバルクインサート用の関数を呼び出す
-	insertedOrderIDs, err := s.store.OrderRepo.CreateOrders(ctx, userID, productIDsToOrder)
+	groupID, err := store.OrderGroupRepo.Create(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
+	insertedOrderIDs, err := store.OrderRepo.CreateOrders(ctx, userID, groupID, productIDsToOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	orderIDs := make([]int64, len(insertedOrderIDs))
+	for i, id := range insertedOrderIDs {
+		orderIDs[i], err = strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	history := statusChanges(orderIDs, "", model.StatusShipping, userActor(userID))
+	if err := store.StatusHistRepo.Record(ctx, history); err != nil {
+		return nil, err
+	}
 
-	log.Printf("Created %d orders for user %d", len(insertedOrderIDs), userID)
-	return insertedOrderIDs, nil
+	result.GroupID = groupID
+	result.OrderIDs = insertedOrderIDs
+	return result, nil
 }
 
-func (s *ProductService) FetchProducts(ctx context.Context, userID int, req model.ListRequest) ([]model.Product, int, error) {
-	products, total, err := s.store.ProductRepo.ListProducts(ctx, userID, req)
-	return products, total, err
-}
\ No newline at end of file
+// 同じキーで異なる内容のリクエストが送られていないか判定するためのハッシュ
+func hashRequestItems(items []model.RequestItem) (string, error) {
+	b, err := json.Marshal(items)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
+
+func replayIdempotencyKey(rec *model.IdempotencyKey) (*CreateOrdersResult, error) {
+	result := &CreateOrdersResult{
+		GroupID:    rec.GroupID.Int64,
+		OrderIDs:   []string{},
+		StatusCode: int(rec.StatusCode.Int64),
+		Replayed:   true,
+	}
+	if rec.OrderIDs.Valid {
+		if err := json.Unmarshal([]byte(rec.OrderIDs.String), &result.OrderIDs); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
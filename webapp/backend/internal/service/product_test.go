@@ -0,0 +1,101 @@
+package service
+
+import (
+	"backend/internal/dbtest"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 並行して注文を作成しても、order_id_sequence で確保した注文IDが重複せず、
+// 返した注文IDと実際に挿入された行が一致することを確認する
+func TestCreateOrdersConcurrentIDsAreDisjoint(t *testing.T) {
+	db := dbtest.Open(t)
+	db.SetMaxOpenConns(16)
+	svc := NewProductService(repository.NewStore(db), NewOrderCache(100, time.Second))
+
+	const workers = 8
+	const requestsPerWorker = 5
+	users := make([]int, workers)
+	for i := range users {
+		users[i] = dbtest.CreateUser(t, db, fmt.Sprintf("user%d", i))
+	}
+	productID := dbtest.CreateProduct(t, db, "product", 1)
+
+	var mu sync.Mutex
+	returned := make(map[string]int) // 注文ID → ユーザーID
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*requestsPerWorker)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(userID, quantity int) {
+			defer wg.Done()
+			for i := 0; i < requestsPerWorker; i++ {
+				items := []model.RequestItem{{ProductID: productID, Quantity: quantity}}
+				result, err := svc.CreateOrders(context.Background(), userID, items)
+				if err != nil {
+					errs <- err
+					return
+				}
+				if len(result.OrderIDs) != quantity {
+					errs <- fmt.Errorf("user %d: got %d order ids, want %d", userID, len(result.OrderIDs), quantity)
+					return
+				}
+				mu.Lock()
+				for _, id := range result.OrderIDs {
+					if prev, dup := returned[id]; dup {
+						errs <- fmt.Errorf("order id %s returned to users %d and %d", id, prev, userID)
+					}
+					returned[id] = userID
+				}
+				mu.Unlock()
+			}
+		}(users[w], w+1)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if t.Failed() {
+		return
+	}
+
+	var rows []struct {
+		OrderID int64 `db:"order_id"`
+		UserID  int   `db:"user_id"`
+	}
+	if err := db.Select(&rows, "SELECT order_id, user_id FROM orders"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(returned) {
+		t.Fatalf("inserted %d rows, returned %d order ids", len(rows), len(returned))
+	}
+	for _, row := range rows {
+		id := fmt.Sprint(row.OrderID)
+		userID, ok := returned[id]
+		if !ok {
+			t.Errorf("order %s was inserted but not returned", id)
+		} else if userID != row.UserID {
+			t.Errorf("order %s belongs to user %d, returned to user %d", id, row.UserID, userID)
+		}
+	}
+
+	ids := make([]int64, len(rows))
+	for i, row := range rows {
+		ids[i] = row.OrderID
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	var lastID int64
+	if err := db.Get(&lastID, "SELECT last_id FROM order_id_sequence WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if ids[len(ids)-1] > lastID {
+		t.Errorf("order id %d is beyond order_id_sequence.last_id %d", ids[len(ids)-1], lastID)
+	}
+}
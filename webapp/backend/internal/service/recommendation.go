@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+var ErrProductNotFound = errors.New("product not found")
+
+const (
+	// 1商品あたりに保存する関連商品の数
+	maxRelatedPerProduct = 20
+	// 1ユーザーの購入商品の組み合わせを数える上限（最近購入したものから数える）
+	// 大量に購入しているユーザーで組み合わせ数が膨らまないようにする
+	maxProductsPerUser = 200
+	// 返却するおすすめ商品の数の上限
+	maxRecommendationLimit     = 50
+	defaultRecommendationLimit = 10
+)
+
+type RecommendationService struct {
+	store *repository.Store
+}
+
+func NewRecommendationService(store *repository.Store) *RecommendationService {
+	return &RecommendationService{store: store}
+}
+
+// 商品と一緒に購入されている商品を取得
+// 一緒に購入された実績が足りない場合は人気商品で補う
+func (s *RecommendationService) Related(ctx context.Context, productID, limit int) ([]model.RecommendedProduct, error) {
+	limit = recommendationLimit(limit)
+
+	ids, err := s.store.ProductRepo.FindExistingIDs(ctx, []int{productID})
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrProductNotFound
+	}
+
+	related, err := s.store.RecommendRepo.ListRelated(ctx, productID, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range related {
+		related[i].Reason = model.RecommendationReasonCoPurchase
+	}
+	return s.fillWithPopular(ctx, related, 0, []int{productID}, limit)
+}
+
+// ユーザーへのおすすめ商品を取得
+// 購入履歴のないユーザーには人気商品を返す
+func (s *RecommendationService) ForUser(ctx context.Context, userID, limit int) ([]model.RecommendedProduct, error) {
+	limit = recommendationLimit(limit)
+
+	recs, err := s.store.RecommendRepo.ListForUser(ctx, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range recs {
+		recs[i].Reason = model.RecommendationReasonCoPurchase
+	}
+	return s.fillWithPopular(ctx, recs, userID, nil, limit)
+}
+
+// 件数が limit に満たない場合、まだ含まれていない人気商品で埋める
+func (s *RecommendationService) fillWithPopular(ctx context.Context, recs []model.RecommendedProduct, userID int, excludeIDs []int, limit int) ([]model.RecommendedProduct, error) {
+	if len(recs) >= limit {
+		return recs, nil
+	}
+	for _, r := range recs {
+		excludeIDs = append(excludeIDs, r.ProductID)
+	}
+	popular, err := s.store.RecommendRepo.ListPopular(ctx, userID, excludeIDs, limit-len(recs))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range popular {
+		p.Reason = model.RecommendationReasonPopular
+		recs = append(recs, p)
+	}
+	return recs, nil
+}
+
+// 起動時と interval ごとに商品間の類似度と人気度を再計算する
+func (s *RecommendationService) RunRefresher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Refresh(ctx); err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Error("Failed to refresh product recommendations", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 注文履歴から商品間の類似度と人気度を計算し、保存済みの値と入れ替える
+func (s *RecommendationService) Refresh(ctx context.Context) error {
+	start := time.Now()
+
+	counts := newCoPurchaseCounts()
+	var basket []int
+	currentUser := -1
+	err := s.store.RecommendRepo.StreamUserProducts(ctx, func(userID, productID int) error {
+		if userID != currentUser {
+			counts.add(basket)
+			basket = basket[:0]
+			currentUser = userID
+		}
+		basket = append(basket, productID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	counts.add(basket)
+	sims, pops := counts.compute()
+
+	computedAt := time.Now()
+	err = s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		if err := txStore.RecommendRepo.ReplaceSimilarities(ctx, sims, computedAt); err != nil {
+			return err
+		}
+		return txStore.RecommendRepo.ReplacePopularity(ctx, pops, computedAt)
+	})
+	if err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("Refreshed product recommendations",
+		"users", counts.users, "products", len(counts.buyers), "similarities", len(sims), "duration", time.Since(start))
+	return nil
+}
+
+// 商品ごとの購入者数と、商品の組ごとに両方を購入したユーザー数
+type coPurchaseCounts struct {
+	users  int
+	buyers map[int]int
+	// キーは (小さい商品ID, 大きい商品ID)
+	pairs map[[2]int]int
+}
+
+func newCoPurchaseCounts() *coPurchaseCounts {
+	return &coPurchaseCounts{buyers: make(map[int]int), pairs: make(map[[2]int]int)}
+}
+
+// ユーザー1人が購入した商品（重複なし、最近購入したものから順）を数える
+// 組み合わせは最近購入した maxProductsPerUser 件の間だけで数える
+func (c *coPurchaseCounts) add(basket []int) {
+	if len(basket) == 0 {
+		return
+	}
+	c.users++
+	for _, p := range basket {
+		c.buyers[p]++
+	}
+	if len(basket) > maxProductsPerUser {
+		basket = basket[:maxProductsPerUser]
+	}
+	for i := 0; i < len(basket); i++ {
+		for j := i + 1; j < len(basket); j++ {
+			a, b := basket[i], basket[j]
+			if a > b {
+				a, b = b, a
+			}
+			c.pairs[[2]int{a, b}]++
+		}
+	}
+}
+
+// 商品間の類似度と人気度を求める
+//
+// 類似度は、商品を購入したユーザーの集合のコサイン類似度
+// |A∩B| / sqrt(|A|·|B|) で、同じユーザーに購入された商品ほど高くなる。
+// 商品ごとに類似度の高い maxRelatedPerProduct 件を残す。
+// 人気度は、商品を購入したユーザーの割合
+func (c *coPurchaseCounts) compute() ([]model.ProductSimilarity, []model.ProductPopularity) {
+	byProduct := make(map[int][]model.ProductSimilarity)
+	for pair, co := range c.pairs {
+		a, b := pair[0], pair[1]
+		score := float64(co) / math.Sqrt(float64(c.buyers[a])*float64(c.buyers[b]))
+		byProduct[a] = append(byProduct[a], model.ProductSimilarity{ProductID: a, RelatedProductID: b, Score: score, CoPurchases: co})
+		byProduct[b] = append(byProduct[b], model.ProductSimilarity{ProductID: b, RelatedProductID: a, Score: score, CoPurchases: co})
+	}
+	var sims []model.ProductSimilarity
+	for _, list := range byProduct {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Score != list[j].Score {
+				return list[i].Score > list[j].Score
+			}
+			return list[i].RelatedProductID < list[j].RelatedProductID
+		})
+		if len(list) > maxRelatedPerProduct {
+			list = list[:maxRelatedPerProduct]
+		}
+		sims = append(sims, list...)
+	}
+
+	pops := make([]model.ProductPopularity, 0, len(c.buyers))
+	for productID, n := range c.buyers {
+		pops = append(pops, model.ProductPopularity{
+			ProductID:  productID,
+			BuyerCount: n,
+			Score:      float64(n) / float64(c.users),
+		})
+	}
+	return sims, pops
+}
+
+func recommendationLimit(limit int) int {
+	if limit <= 0 {
+		return defaultRecommendationLimit
+	}
+	if limit > maxRecommendationLimit {
+		return maxRecommendationLimit
+	}
+	return limit
+}
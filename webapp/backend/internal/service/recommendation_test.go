@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"backend/internal/dbtest"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+func TestCoPurchaseSimilarity(t *testing.T) {
+	c := newCoPurchaseCounts()
+	c.add([]int{2, 1})
+	c.add([]int{3, 1, 2})
+	c.add([]int{3})
+	c.add(nil)
+	sims, pops := c.compute()
+
+	// 購入者: 1 = {u1, u2}, 2 = {u1, u2}, 3 = {u2, u3}
+	want := map[[2]int]float64{
+		{1, 2}: 1,
+		{2, 1}: 1,
+		{1, 3}: 0.5,
+		{3, 1}: 0.5,
+		{2, 3}: 0.5,
+		{3, 2}: 0.5,
+	}
+	if len(sims) != len(want) {
+		t.Fatalf("similarities = %+v, want %d", sims, len(want))
+	}
+	for _, s := range sims {
+		if w, ok := want[[2]int{s.ProductID, s.RelatedProductID}]; !ok || math.Abs(s.Score-w) > 1e-9 {
+			t.Errorf("similarity %d -> %d = %v, want %v", s.ProductID, s.RelatedProductID, s.Score, w)
+		}
+	}
+
+	if len(pops) != 3 {
+		t.Fatalf("popularity = %+v, want 3 products", pops)
+	}
+	for _, p := range pops {
+		if p.BuyerCount != 2 || math.Abs(p.Score-2.0/3) > 1e-9 {
+			t.Errorf("popularity of %d = %+v, want 2 buyers of 3 users", p.ProductID, p)
+		}
+	}
+}
+
+func TestCoPurchaseCapKeepsRecentProducts(t *testing.T) {
+	// 最近購入したものから並べ、最も古い購入だけが上限を超える
+	basket := make([]int, maxProductsPerUser+1)
+	for i := range basket {
+		basket[i] = maxProductsPerUser + 1 - i
+	}
+	c := newCoPurchaseCounts()
+	c.add(basket)
+
+	oldest := basket[len(basket)-1]
+	if c.buyers[oldest] != 1 {
+		t.Errorf("buyers of the oldest product = %d, want 1", c.buyers[oldest])
+	}
+	for pair := range c.pairs {
+		if pair[0] == oldest || pair[1] == oldest {
+			t.Fatalf("pair %v includes the oldest product beyond the cap", pair)
+		}
+	}
+	if want := maxProductsPerUser * (maxProductsPerUser - 1) / 2; len(c.pairs) != want {
+		t.Errorf("pairs = %d, want %d", len(c.pairs), want)
+	}
+}
+
+func TestRecommendationsFallBackToPopular(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	s := NewRecommendationService(repository.NewStore(db))
+
+	p1 := dbtest.CreateProduct(t, db, "p1", 1)
+	p2 := dbtest.CreateProduct(t, db, "p2", 1)
+	p3 := dbtest.CreateProduct(t, db, "p3", 1)
+	a := dbtest.CreateUser(t, db, "a")
+	b := dbtest.CreateUser(t, db, "b")
+	c := dbtest.CreateUser(t, db, "c")
+	newUser := dbtest.CreateUser(t, db, "new")
+	now := time.Now()
+	for _, o := range []struct{ user, product int }{{a, p1}, {a, p2}, {b, p1}, {c, p3}} {
+		dbtest.CreateOrder(t, db, o.user, o.product, model.StatusCompleted, now)
+	}
+	// キャンセルされた注文は購入に含めない
+	dbtest.CreateOrder(t, db, c, p2, model.StatusCancelled, now)
+	if err := s.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	type rec struct {
+		ProductID int
+		Reason    string
+	}
+	collect := func(recs []model.RecommendedProduct) []rec {
+		out := make([]rec, len(recs))
+		for i, r := range recs {
+			out[i] = rec{r.ProductID, r.Reason}
+		}
+		return out
+	}
+	tests := []struct {
+		name string
+		get  func() ([]model.RecommendedProduct, error)
+		want []rec
+	}{
+		{
+			// 購入履歴がなければ人気の高い順（同じ場合は商品ID順）
+			name: "new user",
+			get:  func() ([]model.RecommendedProduct, error) { return s.ForUser(ctx, newUser, 3) },
+			want: []rec{{p1, model.RecommendationReasonPopular}, {p2, model.RecommendationReasonPopular}, {p3, model.RecommendationReasonPopular}},
+		},
+		{
+			// 一緒に購入された商品の後に、購入済みでない人気商品で埋める
+			name: "user with history",
+			get:  func() ([]model.RecommendedProduct, error) { return s.ForUser(ctx, b, 3) },
+			want: []rec{{p2, model.RecommendationReasonCoPurchase}, {p3, model.RecommendationReasonPopular}},
+		},
+		{
+			name: "related",
+			get:  func() ([]model.RecommendedProduct, error) { return s.Related(ctx, p2, 3) },
+			want: []rec{{p1, model.RecommendationReasonCoPurchase}, {p3, model.RecommendationReasonPopular}},
+		},
+	}
+	for _, tt := range tests {
+		recs, err := tt.get()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got := collect(recs)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
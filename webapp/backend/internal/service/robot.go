@@ -2,21 +2,33 @@ package service
 
 import (
 	"context"
-	"log"
+	"database/sql"
+	"errors"
 	"sync"
 	"time"
+	"backend/internal/events"
+	"backend/internal/logging"
 	"backend/internal/model"
 	"backend/internal/repository"
 	"backend/internal/service/utils"
 	"strconv"
 )
 
+// 配送計画の作成中に注文の状態が変わった（キャンセルされた）
+// 作り直しても解消しなかった場合に返す。時間をおいて再試行すればよい
+var ErrDeliveryPlanConflict = errors.New("delivery plan conflicted with concurrent order changes")
+
+// 配送計画の作成を試みる最大回数
+const maxPlanAttempts = 3
+
 type RobotService struct {
-	store *repository.Store
+	store      *repository.Store
+	orderCache *OrderCache
+	hub        *events.Hub
 }
 
-func NewRobotService(store *repository.Store) *RobotService {
-	return &RobotService{store: store}
+func NewRobotService(store *repository.Store, orderCache *OrderCache, hub *events.Hub) *RobotService {
+	return &RobotService{store: store, orderCache: orderCache, hub: hub}
 }
 
 // キャッシュ用の構造体
@@ -57,6 +69,7 @@ func (s *RobotService) GenerateDeliveryPlan(ctx context.Context, robotID string,
                 }
             }
             if allShipping {
+                deliveryPlanCacheRequests.WithLabelValues("hit").Inc()
                 planCopy := *cp.plan
                 return &planCopy, nil
             }
@@ -66,33 +79,30 @@ func (s *RobotService) GenerateDeliveryPlan(ctx context.Context, robotID string,
     }
 
 	// ---- DBアクセスして生成 ----
+	deliveryPlanCacheRequests.WithLabelValues("miss").Inc()
+	start := time.Now()
 	var plan model.DeliveryPlan
+	var changes []events.OrderStatusEvent
 	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
-		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
-			orders, err := txStore.OrderRepo.GetShippingOrders(ctx)
-			if err != nil {
-				return err
-			}
-			plan, err = selectOrdersForDelivery(ctx, orders, robotID, capacity)
-			if err != nil {
+		var err error
+		// 計画の作成中に注文がキャンセルされた場合は、作り直す
+		for attempt := 0; attempt < maxPlanAttempts; attempt++ {
+			err = s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+				return s.claimDeliveryPlan(ctx, txStore, robotID, capacity, &plan, &changes)
+			})
+			if !errors.Is(err, ErrDeliveryPlanConflict) {
 				return err
 			}
-			if len(plan.Orders) > 0 {
-				orderIDs := make([]int64, len(plan.Orders))
-				for i, order := range plan.Orders {
-					orderIDs[i] = order.OrderID
-				}
-				if err := txStore.OrderRepo.UpdateStatuses(ctx, orderIDs, "delivering"); err != nil {
-					return err
-				}
-				log.Printf("Updated status to 'delivering' for %d orders", len(orderIDs))
-			}
-			return nil
-		})
+			logging.FromContext(ctx).Warn("Delivery plan conflicted with concurrent order changes, retrying", "attempt", attempt+1)
+		}
+		return err
 	})
+	deliveryPlanDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
 		return nil, err
 	}
+	deliveryPlanOrders.Observe(float64(len(plan.Orders)))
+	notifyStatusChanges(s.orderCache, s.hub, changes)
 
 	// ---- キャッシュ保存 ----
 	deliveryPlanCache.Lock()
@@ -107,10 +117,95 @@ func (s *RobotService) GenerateDeliveryPlan(ctx context.Context, robotID string,
 
 
 
-func (s *RobotService) UpdateOrderStatus(ctx context.Context, orderID int64, newStatus string) error {
-	return utils.WithTimeout(ctx, func(ctx context.Context) error {
-		return s.store.OrderRepo.UpdateStatuses(ctx, []int64{orderID}, newStatus)
+// 配送計画を作成し、計画に含めた注文を引き受け済み(delivering)にする
+// 引き受けるまでに状態が変わった注文があれば ErrDeliveryPlanConflict を返す
+func (s *RobotService) claimDeliveryPlan(
+	ctx context.Context,
+	txStore *repository.Store,
+	robotID string,
+	capacity int,
+	plan *model.DeliveryPlan,
+	changes *[]events.OrderStatusEvent,
+) error {
+	orders, err := txStore.OrderRepo.GetShippingOrders(ctx)
+	if err != nil {
+		return err
+	}
+	deliveryPlanBacklog.Set(float64(len(orders)))
+	*plan, err = selectOrdersForDelivery(ctx, orders, robotID, capacity)
+	if err != nil {
+		return err
+	}
+	if len(plan.Orders) == 0 {
+		return nil
+	}
+
+	orderIDs := make([]int64, len(plan.Orders))
+	for i, order := range plan.Orders {
+		orderIDs[i] = order.OrderID
+	}
+	claimed, err := txStore.OrderRepo.ClaimShippingOrders(ctx, orderIDs, robotID)
+	if err != nil {
+		return err
+	}
+	if claimed != int64(len(orderIDs)) {
+		return ErrDeliveryPlanConflict
+	}
+	history := statusChanges(orderIDs, model.StatusShipping, model.StatusDelivering, robotActor(robotID))
+	if err := txStore.StatusHistRepo.Record(ctx, history); err != nil {
+		return err
+	}
+	owners, err := txStore.OrderRepo.GetOwnersByOrderIDs(ctx, orderIDs)
+	if err != nil {
+		return err
+	}
+	*changes = statusEvents(history, owners)
+	logging.FromContext(ctx).Info("Updated status to 'delivering'", "robot_id", robotID, "orders", len(orderIDs))
+	return nil
+}
+
+// 存在しない注文の場合は ErrOrderNotFound を返す
+func (s *RobotService) UpdateOrderStatus(ctx context.Context, robotID string, orderID int64, newStatus string) error {
+	var changes []events.OrderStatusEvent
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			oldStatus, err := txStore.OrderRepo.GetStatusForUpdate(ctx, orderID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrOrderNotFound
+			}
+			if err != nil {
+				return err
+			}
+			// キャンセルされた注文を配送中などに戻すと、次の配送計画に含まれてしまう
+			if oldStatus == model.StatusCancelled && newStatus != model.StatusCancelled {
+				return ErrOrderCancelled
+			}
+			if err := txStore.OrderRepo.UpdateStatuses(ctx, []int64{orderID}, oldStatus, newStatus, robotID); err != nil {
+				return err
+			}
+			// 配達完了にした時刻を到着日時とする
+			if newStatus == model.StatusCompleted {
+				if err := txStore.OrderRepo.RecordArrival(ctx, orderID, time.Now()); err != nil {
+					return err
+				}
+			}
+			history := statusChanges([]int64{orderID}, oldStatus, newStatus, robotActor(robotID))
+			if err := txStore.StatusHistRepo.Record(ctx, history); err != nil {
+				return err
+			}
+			owners, err := txStore.OrderRepo.GetOwnersByOrderIDs(ctx, []int64{orderID})
+			if err != nil {
+				return err
+			}
+			changes = statusEvents(history, owners)
+			return nil
+		})
 	})
+	if err != nil {
+		return err
+	}
+	notifyStatusChanges(s.orderCache, s.hub, changes)
+	return nil
 }
 
 func selectOrdersForDelivery(
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"backend/internal/dbtest"
+	"backend/internal/events"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUpdateOrderStatusRejectsCancelledOrder(t *testing.T) {
+	db := dbtest.Open(t)
+	svc := NewRobotService(repository.NewStore(db), NewOrderCache(100, time.Second), events.NewHub())
+	userID := dbtest.CreateUser(t, db, "user")
+	productID := dbtest.CreateProduct(t, db, "product", 1)
+	orderID := dbtest.CreateOrder(t, db, userID, productID, model.StatusCancelled, time.Now())
+
+	for _, status := range []string{model.StatusShipping, model.StatusDelivering, model.StatusCompleted} {
+		err := svc.UpdateOrderStatus(context.Background(), "robot-test", orderID, status)
+		if !errors.Is(err, ErrOrderCancelled) {
+			t.Errorf("cancelled -> %s: err = %v, want ErrOrderCancelled", status, err)
+		}
+	}
+
+	var status string
+	if err := db.Get(&status, "SELECT shipped_status FROM orders WHERE order_id = ?", orderID); err != nil {
+		t.Fatal(err)
+	}
+	if status != model.StatusCancelled {
+		t.Errorf("shipped_status = %q, want %q", status, model.StatusCancelled)
+	}
+}
+
+func TestUpdateOrderStatusUnknownOrder(t *testing.T) {
+	db := dbtest.Open(t)
+	svc := NewRobotService(repository.NewStore(db), NewOrderCache(100, time.Second), events.NewHub())
+
+	err := svc.UpdateOrderStatus(context.Background(), "robot-test", 12345, model.StatusDelivering)
+	if !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("err = %v, want ErrOrderNotFound", err)
+	}
+}
+
+func TestUpdateOrderStatusRecordsChange(t *testing.T) {
+	db := dbtest.Open(t)
+	svc := NewRobotService(repository.NewStore(db), NewOrderCache(100, time.Second), events.NewHub())
+	userID := dbtest.CreateUser(t, db, "user")
+	productID := dbtest.CreateProduct(t, db, "product", 1)
+	orderID := dbtest.CreateOrder(t, db, userID, productID, model.StatusShipping, time.Now())
+
+	if err := svc.UpdateOrderStatus(context.Background(), "robot-7", orderID, model.StatusDelivering); err != nil {
+		t.Fatal(err)
+	}
+	var actor string
+	if err := db.Get(&actor, "SELECT actor FROM order_status_history WHERE order_id = ? AND new_status = ?", orderID, model.StatusDelivering); err != nil {
+		t.Fatal(err)
+	}
+	if actor != "robot:robot-7" {
+		t.Errorf("actor = %q, want %q", actor, "robot:robot-7")
+	}
+
+	var oldStatus string
+	query := "SELECT payload->>'$.old_status' FROM domain_events WHERE aggregate_id = ? AND event_type = ?"
+	if err := db.Get(&oldStatus, query, orderID, model.DomainEventOrderStatusChanged); err != nil {
+		t.Fatal(err)
+	}
+	if oldStatus != model.StatusShipping {
+		t.Errorf("status_changed old_status = %q, want %q", oldStatus, model.StatusShipping)
+	}
+}
+
+func TestUpdateOrderStatusRecordsArrival(t *testing.T) {
+	db := dbtest.Open(t)
+	svc := NewRobotService(repository.NewStore(db), NewOrderCache(100, time.Second), events.NewHub())
+	userID := dbtest.CreateUser(t, db, "user")
+	productID := dbtest.CreateProduct(t, db, "product", 1)
+	orderID := dbtest.CreateOrder(t, db, userID, productID, model.StatusDelivering, time.Now())
+	arrivedAt := func() sql.NullTime {
+		t.Helper()
+		var at sql.NullTime
+		if err := db.Get(&at, "SELECT arrived_at FROM orders WHERE order_id = ?", orderID); err != nil {
+			t.Fatal(err)
+		}
+		return at
+	}
+
+	if err := svc.UpdateOrderStatus(context.Background(), "robot-1", orderID, model.StatusDelivering); err != nil {
+		t.Fatal(err)
+	}
+	if at := arrivedAt(); at.Valid {
+		t.Fatalf("arrived_at = %v before completion, want NULL", at.Time)
+	}
+
+	if err := svc.UpdateOrderStatus(context.Background(), "robot-1", orderID, model.StatusCompleted); err != nil {
+		t.Fatal(err)
+	}
+	first := arrivedAt()
+	if !first.Valid {
+		t.Fatal("arrived_at is NULL after completion")
+	}
+	// 完了の通知が重複しても最初の到着日時を残す
+	time.Sleep(1100 * time.Millisecond)
+	if err := svc.UpdateOrderStatus(context.Background(), "robot-1", orderID, model.StatusCompleted); err != nil {
+		t.Fatal(err)
+	}
+	if again := arrivedAt(); !again.Time.Equal(first.Time) {
+		t.Errorf("arrived_at = %v after a repeated completion, want %v", again.Time, first.Time)
+	}
+}
@@ -2,8 +2,9 @@ package utils
 
 import (
 	"context"
-	"log"
 	"time"
+
+	"backend/internal/logging"
 )
 
 var defaultTimeout = 120 * time.Second
@@ -27,7 +28,7 @@ func WithTimeout(parent context.Context, fn func(ctx context.Context) error) err
 	case err := <-done:
 		return err
 	case <-ctx.Done():
-		log.Printf("処理がタイムアウトしました (timeout=%s)", timeout)
+		logging.FromContext(parent).Warn("処理がタイムアウトしました", "timeout", timeout)
 		return ctx.Err()
 	}
 }
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+const (
+	// 1回の注文リクエストに含められる商品の最大数
+	maxOrderItems = 100
+	// 1商品あたりの最大注文数
+	maxOrderQuantity = 1000
+)
+
+// バリデーションエラーのコード
+const (
+	ValidationCodeTooManyItems       = "too_many_items"
+	ValidationCodeUnknownProduct     = "unknown_product"
+	ValidationCodeQuantityOutOfRange = "quantity_out_of_range"
+)
+
+// 項目ごとのバリデーションエラー
+// Field は "items[2].quantity" のようにリクエスト内の位置を表す
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// リクエストの内容が不正な場合のエラー
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// 注文リクエストの商品の存在と数量を検証する
+func validateOrderItems(ctx context.Context, store *repository.Store, items []model.RequestItem) error {
+	if len(items) > maxOrderItems {
+		return &ValidationError{Errors: []FieldError{{
+			Field:   "items",
+			Code:    ValidationCodeTooManyItems,
+			Message: fmt.Sprintf("at most %d items can be ordered at once", maxOrderItems),
+		}}}
+	}
+
+	productIDs := make([]int, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	existingIDs, err := store.ProductRepo.FindExistingIDs(ctx, productIDs)
+	if err != nil {
+		return err
+	}
+	exists := make(map[int]bool, len(existingIDs))
+	for _, id := range existingIDs {
+		exists[id] = true
+	}
+
+	var errs []FieldError
+	for i, item := range items {
+		if !exists[item.ProductID] {
+			errs = append(errs, FieldError{
+				Field:   fmt.Sprintf("items[%d].product_id", i),
+				Code:    ValidationCodeUnknownProduct,
+				Message: fmt.Sprintf("product %d does not exist", item.ProductID),
+			})
+		}
+		if item.Quantity < 1 || item.Quantity > maxOrderQuantity {
+			errs = append(errs, quantityOutOfRange(fmt.Sprintf("items[%d].quantity", i)))
+		}
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func quantityOutOfRange(field string) FieldError {
+	return FieldError{
+		Field:   field,
+		Code:    ValidationCodeQuantityOutOfRange,
+		Message: fmt.Sprintf("quantity must be between 1 and %d", maxOrderQuantity),
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+var ErrWebhookEndpointNotFound = errors.New("webhook endpoint not found")
+
+// Webhook のバリデーションエラーのコード
+const (
+	ValidationCodeInvalidURL       = "invalid_url"
+	ValidationCodeUnknownEventType = "unknown_event_type"
+)
+
+// 配送ログの取得件数の上限
+const maxWebhookDeliveryLimit = 500
+
+type WebhookService struct {
+	store *repository.Store
+}
+
+func NewWebhookService(store *repository.Store) *WebhookService {
+	return &WebhookService{store: store}
+}
+
+// 通知先を登録する
+// secret が空の場合は生成する。生成した secret は登録時の応答でのみ返す
+func (s *WebhookService) CreateEndpoint(ctx context.Context, req model.CreateWebhookEndpointRequest) (*model.WebhookEndpoint, error) {
+	var errs []FieldError
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, FieldError{
+			Field:   "url",
+			Code:    ValidationCodeInvalidURL,
+			Message: "url must be an absolute http or https URL",
+		})
+	}
+	for i, t := range req.EventTypes {
+		if !isWebhookEventType(t) {
+			errs = append(errs, FieldError{
+				Field:   fmt.Sprintf("event_types[%d]", i),
+				Code:    ValidationCodeUnknownEventType,
+				Message: "unknown event type " + t,
+			})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationError{Errors: errs}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		secret = hex.EncodeToString(b)
+	}
+
+	ep := &model.WebhookEndpoint{
+		URL:        req.URL,
+		Secret:     secret,
+		EventTypes: strings.Join(req.EventTypes, ","),
+		Active:     true,
+		CreatedAt:  time.Now(),
+	}
+	id, err := s.store.WebhookRepo.CreateEndpoint(ctx, ep)
+	if err != nil {
+		return nil, err
+	}
+	ep.EndpointID = id
+	return ep, nil
+}
+
+// 登録済みの通知先を取得する（secret は返さない）
+func (s *WebhookService) ListEndpoints(ctx context.Context) ([]model.WebhookEndpoint, error) {
+	endpoints, err := s.store.WebhookRepo.ListEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range endpoints {
+		endpoints[i].Secret = ""
+	}
+	return endpoints, nil
+}
+
+// 通知先を無効にする
+// 未送信の配送は送信されなくなる
+func (s *WebhookService) DeactivateEndpoint(ctx context.Context, endpointID int64) error {
+	ok, err := s.store.WebhookRepo.DeactivateEndpoint(ctx, endpointID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrWebhookEndpointNotFound
+	}
+	return nil
+}
+
+// 通知先の配送状況を新しい順に取得する
+func (s *WebhookService) ListDeliveries(ctx context.Context, endpointID int64, limit int) ([]model.WebhookDelivery, error) {
+	if limit <= 0 || limit > maxWebhookDeliveryLimit {
+		limit = maxWebhookDeliveryLimit
+	}
+	return s.store.WebhookRepo.ListDeliveries(ctx, endpointID, limit)
+}
+
+// 配送の試行ログを取得する
+func (s *WebhookService) ListAttempts(ctx context.Context, deliveryID int64) ([]model.WebhookDeliveryAttempt, error) {
+	return s.store.WebhookRepo.ListAttempts(ctx, deliveryID)
+}
+
+func isWebhookEventType(t string) bool {
+	switch t {
+	case model.WebhookEventOrderCreated, model.WebhookEventOrderClaimed, model.WebhookEventOrderDelivered:
+		return true
+	}
+	return false
+}
+
+// 保持期間を過ぎた送信済みの配送を定期的に削除する
+func (s *WebhookService) RunDeliveryPurger(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.store.WebhookRepo.DeleteFinishedDeliveries(ctx, time.Now().Add(-retention))
+			if err != nil {
+				logging.FromContext(ctx).Error("Failed to purge webhook deliveries", "error", err)
+				continue
+			}
+			if n > 0 {
+				logging.FromContext(ctx).Info("Purged webhook deliveries", "deliveries", n)
+			}
+		}
+	}
+}
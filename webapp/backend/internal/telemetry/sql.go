@@ -2,14 +2,15 @@ package telemetry
 
 import (
 	"database/sql"
-	"log"
+	"log/slog"
 
 	"github.com/XSAM/otelsql"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
-func WrapSQLDriver(baseDriver string) string {
-	if !enabled() {
+// enabled が false の場合は計装せずにそのまま返す
+func WrapSQLDriver(baseDriver string, enabled bool) string {
+	if !enabled {
 		return baseDriver
 	}
 	name, err := otelsql.Register(baseDriver,
@@ -18,7 +19,7 @@ func WrapSQLDriver(baseDriver string) string {
 		otelsql.WithSpanOptions(otelsql.SpanOptions{DisableErrSkip: true}),
 	)
 	if err != nil {
-		log.Printf("otelsql.Register failed, fallback to base driver: %v", err)
+		slog.Warn("otelsql.Register failed, fallback to base driver", "error", err)
 		return baseDriver
 	}
 	return name
@@ -2,9 +2,8 @@
 package telemetry
 
 import (
+	"backend/internal/config"
 	"context"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -19,59 +18,37 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
-func enabled() bool {
-	if strings.EqualFold(os.Getenv("TRACE_ENABLED"), "false") {
-		return false
-	}
-	if strings.EqualFold(os.Getenv("TRACE_ENABLED"), "true") {
-		return true
-	}
-	if os.Getenv("JAEGER_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
-		return true
-	}
-	return false
-}
+// 既定のサンプリング率
+const defaultSampleRatio = 0.01
 
-func samplerFromEnv() sdktrace.Sampler {
-	if r := os.Getenv("TRACE_SAMPLE_RATIO"); r != "" {
-		if v, err := strconv.ParseFloat(r, 64); err == nil {
-			return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(v))
-		}
+// TRACE_SAMPLE_RATIO が設定されていれば OTEL_TRACES_SAMPLER より優先する
+func sampler(cfg config.Telemetry) sdktrace.Sampler {
+	if ratio, ok := cfg.Ratio(); ok {
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
 	}
-	switch strings.ToLower(os.Getenv("OTEL_TRACES_SAMPLER")) {
+	switch strings.ToLower(cfg.Sampler) {
 	case "always_off":
 		return sdktrace.NeverSample()
 	case "always_on":
 		return sdktrace.AlwaysSample()
 	}
-	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(0.01))
+	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(defaultSampleRatio))
 }
 
-func resourceFromEnv() *resource.Resource {
-	svc := os.Getenv("SERVICE_NAME")
-	if svc == "" {
-		svc = "backend"
-	}
-	env := os.Getenv("ENV")
-	if env == "" {
-		env = os.Getenv("GO_ENV")
-		if env == "" {
-			env = "local"
-		}
-	}
+func newResource(cfg config.Telemetry) *resource.Resource {
 	r, _ := resource.Merge(
 		resource.Default(),
 		resource.NewWithAttributes(
 			semconv.SchemaURL,
-			semconv.ServiceName(svc),
-			attribute.String("deployment.environment", env),
+			semconv.ServiceName(cfg.ServiceName),
+			attribute.String("deployment.environment", cfg.Environment),
 		),
 	)
 	return r
 }
 
-func Init(ctx context.Context) (func(context.Context) error, error) {
-	if !enabled() {
+func Init(ctx context.Context, cfg config.Telemetry) (func(context.Context) error, error) {
+	if !cfg.On() {
 		otel.SetTracerProvider(trace.NewNoopTracerProvider())
 		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
 			propagation.TraceContext{}, propagation.Baggage{},
@@ -83,9 +60,9 @@ func Init(ctx context.Context) (func(context.Context) error, error) {
 		exp sdktrace.SpanExporter
 		err error
 	)
-	if ep := os.Getenv("JAEGER_ENDPOINT"); ep != "" {
+	if ep := cfg.JaegerEndpoint; ep != "" {
 		exp, err = jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(ep)))
-	} else if ep := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); ep != "" {
+	} else if ep := cfg.OTLPEndpoint; ep != "" {
 		exp, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpoint(ep), otlptracehttp.WithInsecure())
 	}
 	if err != nil || exp == nil {
@@ -94,12 +71,12 @@ func Init(ctx context.Context) (func(context.Context) error, error) {
 	}
 
 	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(samplerFromEnv()),
+		sdktrace.WithSampler(sampler(cfg)),
 		sdktrace.WithBatcher(exp,
 			sdktrace.WithMaxQueueSize(4096),
 			sdktrace.WithExportTimeout(5*time.Second),
 		),
-		sdktrace.WithResource(resourceFromEnv()),
+		sdktrace.WithResource(newResource(cfg)),
 	)
 	otel.SetTracerProvider(tp)
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
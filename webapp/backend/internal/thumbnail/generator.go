@@ -0,0 +1,319 @@
+package thumbnail
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 縮小できる画像の最大画素数（展開時のメモリ使用量を抑えるため）
+const maxSourcePixels = 40_000_000
+
+const jpegQuality = 85
+
+// 1枚の縮小画像の生成（順番待ちを含む）にかける時間の上限
+const generateTimeout = 30 * time.Second
+
+// 縮小できない画像（未対応の形式や大きすぎる画像）の場合に返す
+var ErrUnsupported = errors.New("unsupported image for thumbnail")
+
+// 生成する縮小画像を表す
+// Hash は元の画像の内容のハッシュ（16進表記）で、キャッシュのキーに使う。
+// Width / Height は収める大きさで、キャッシュには元の画像から実際に縮小した大きさで保存する
+type Key struct {
+	Hash   string
+	Width  int
+	Height int
+	Format string
+}
+
+func (k Key) fileName() string {
+	return fmt.Sprintf("%s_%dx%d.%s", k.Hash, k.Width, k.Height, Ext(k.Format))
+}
+
+// 縮小画像を生成し、ディスクにキャッシュする
+// 同じ縮小画像を同時に要求された場合は1回だけ生成する。
+// 生成は CPU を使うため、同時に生成する数を CPU 数までに制限する。
+// キャッシュの合計サイズが maxBytes を超えた場合は、最も長く使われていないものから削除する
+type Generator struct {
+	dir      string
+	maxBytes int64
+	sem      chan struct{}
+
+	mu       sync.Mutex
+	inflight map[string]*generation
+	// キャッシュ済みのファイル（先頭ほど最近使われた）
+	lru   *list.List
+	files map[string]*list.Element
+	size  int64
+}
+
+type generation struct {
+	done chan struct{}
+	err  error
+}
+
+type cachedFile struct {
+	path string
+	size int64
+}
+
+// dir に残っている縮小画像は更新日時の新しいものから引き継ぐ
+func NewGenerator(dir string, maxBytes int64) (*Generator, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	g := &Generator{
+		dir:      dir,
+		maxBytes: maxBytes,
+		sem:      make(chan struct{}, runtime.NumCPU()),
+		inflight: make(map[string]*generation),
+		lru:      list.New(),
+		files:    make(map[string]*list.Element),
+	}
+	if err := g.load(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// キャッシュのファイルの数と合計サイズ
+func (g *Generator) Stats() (files int, bytes int64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lru.Len(), g.size
+}
+
+// 縮小画像を開いて返す
+// キャッシュにない場合は src から生成する。縮小できない画像の場合は ErrUnsupported を返す
+func (g *Generator) Open(ctx context.Context, key Key, src io.ReadSeeker) (*os.File, error) {
+	if !validHash(key.Hash) || Ext(key.Format) == "" {
+		return nil, fmt.Errorf("invalid thumbnail key %+v", key)
+	}
+	// 展開する前に大きさを確認し、元の画像より大きな指定は元の大きさに揃える
+	cfg, _, err := image.DecodeConfig(src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxSourcePixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels", ErrUnsupported, cfg.Width, cfg.Height)
+	}
+	key.Width, key.Height = Fit(cfg.Width, cfg.Height, key.Width, key.Height)
+	path := filepath.Join(g.dir, key.Hash[:2], key.fileName())
+
+	for {
+		g.mu.Lock()
+		if f, ok := g.openCachedLocked(path); ok {
+			g.mu.Unlock()
+			return f, nil
+		}
+		if gen, ok := g.inflight[path]; ok {
+			g.mu.Unlock()
+			select {
+			case <-gen.done:
+				if gen.err != nil {
+					return nil, gen.err
+				}
+				// 生成されたファイルを開き直す
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		gen := &generation{done: make(chan struct{})}
+		g.inflight[path] = gen
+		g.mu.Unlock()
+
+		// 同じ縮小画像を待っている他のリクエストがあるため、
+		// 最初に要求したリクエストがキャンセルされても生成は続ける
+		genCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), generateTimeout)
+		var f *os.File
+		size, err := g.generate(genCtx, key, src, path)
+		cancel()
+		g.mu.Lock()
+		delete(g.inflight, path)
+		if err == nil {
+			g.addLocked(path, size)
+			var ok bool
+			if f, ok = g.openCachedLocked(path); !ok {
+				err = fmt.Errorf("thumbnail %s disappeared", path)
+			}
+			// 開いた後であれば、削除されても読み込める
+			g.evictLocked()
+		}
+		g.mu.Unlock()
+		gen.err = err
+		close(gen.done)
+		return f, err
+	}
+}
+
+// キャッシュ済みであれば開き、最近使ったものとして記録する
+// ファイルが外部から削除されていた場合はキャッシュから外して ok=false を返す
+func (g *Generator) openCachedLocked(path string) (*os.File, bool) {
+	e, ok := g.files[path]
+	if !ok {
+		return nil, false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		g.removeLocked(e)
+		return nil, false
+	}
+	g.lru.MoveToFront(e)
+	return f, true
+}
+
+func (g *Generator) addLocked(path string, size int64) {
+	if e, ok := g.files[path]; ok {
+		g.removeLocked(e)
+	}
+	g.files[path] = g.lru.PushFront(&cachedFile{path: path, size: size})
+	g.size += size
+}
+
+func (g *Generator) removeLocked(e *list.Element) {
+	c := g.lru.Remove(e).(*cachedFile)
+	delete(g.files, c.path)
+	g.size -= c.size
+}
+
+// 合計サイズが上限以下になるまで、最も長く使われていないファイルを削除する
+func (g *Generator) evictLocked() {
+	for g.size > g.maxBytes && g.lru.Len() > 0 {
+		e := g.lru.Back()
+		path := e.Value.(*cachedFile).path
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			// 削除できないファイルは数え続け、次の機会に再び削除を試みる
+			g.lru.MoveToFront(e)
+			return
+		}
+		g.removeLocked(e)
+	}
+}
+
+// 前回までに生成したファイルを読み込む（書きかけの一時ファイルは削除する）
+func (g *Generator) load() error {
+	type found struct {
+		path    string
+		size    int64
+		modTime int64
+	}
+	var files []found
+	err := filepath.WalkDir(g.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.Contains(d.Name(), ".tmp-") {
+			os.Remove(path)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, found{path: path, size: info.Size(), modTime: info.ModTime().UnixNano()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime < files[j].modTime })
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, f := range files {
+		g.addLocked(f.path, f.size)
+	}
+	g.evictLocked()
+	return nil
+}
+
+// key の大きさ（元の画像に合わせたもの）の縮小画像を生成し、ファイルのサイズを返す
+func (g *Generator) generate(ctx context.Context, key Key, src io.ReadSeeker, path string) (int64, error) {
+	select {
+	case g.sem <- struct{}{}:
+		defer func() { <-g.sem }()
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	thumb := Resize(img, key.Width, key.Height)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	// 一時ファイルに書き込んでから名前を変え、書きかけのファイルを配信しないようにする
+	tmp, err := os.CreateTemp(filepath.Dir(path), key.fileName()+".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := encode(tmp, thumb, key.Format); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return 0, err
+	}
+	return info.Size(), os.Rename(tmp.Name(), path)
+}
+
+func encode(w io.Writer, img *image.RGBA, format string) error {
+	switch format {
+	case FormatJPEG:
+		// JPEG は透過を扱えないため、白の背景に重ねる
+		bg := image.NewRGBA(img.Rect)
+		draw.Draw(bg, bg.Rect, image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(bg, bg.Rect, img, image.Point{}, draw.Over)
+		return jpeg.Encode(w, bg, &jpeg.Options{Quality: jpegQuality})
+	case FormatPNG:
+		return png.Encode(w, img)
+	default:
+		return fmt.Errorf("unknown thumbnail format %q", format)
+	}
+}
+
+// ファイル名に使うため、16進表記であることを確認する
+func validHash(hash string) bool {
+	if len(hash) < 2 {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testHash = "0123456789abcdef"
+
+func testPNG(t *testing.T, w, h int) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i * 7)
+	}
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func openThumbnail(t *testing.T, g *Generator, w, h int) string {
+	t.Helper()
+	f, err := g.Open(context.Background(), Key{Hash: testHash, Width: w, Height: h, Format: "image/png"}, testPNG(t, 100, 50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	return f.Name()
+}
+
+func TestGeneratorClampsToSourceSize(t *testing.T) {
+	g, err := NewGenerator(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 元の画像より大きな指定はすべて元の大きさの1ファイルにまとまる
+	for _, size := range [][2]int{{200, 200}, {1000, 1000}, {2048, 50}} {
+		path := openThumbnail(t, g, size[0], size[1])
+		if got := filepath.Base(path); got != testHash+"_100x50.png" {
+			t.Errorf("%v: file = %s, want %s_100x50.png", size, got, testHash)
+		}
+	}
+	if n, _ := g.Stats(); n != 1 {
+		t.Errorf("cached files = %d, want 1", n)
+	}
+}
+
+func TestGeneratorEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	g, err := NewGenerator(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := openThumbnail(t, g, 40, 40)
+	b := openThumbnail(t, g, 30, 30)
+	_, total := g.Stats()
+
+	// 2ファイル分の上限で作り直すと、既存のファイルを引き継ぐ
+	g, err = NewGenerator(dir, total)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := g.Stats(); n != 2 {
+		t.Fatalf("cached files after restart = %d, want 2", n)
+	}
+	openThumbnail(t, g, 40, 40)
+	c := openThumbnail(t, g, 20, 20)
+
+	// 最も長く使われていない b が削除される
+	if _, err := os.Stat(b); !os.IsNotExist(err) {
+		t.Errorf("%s still exists, want it evicted", b)
+	}
+	for _, path := range []string{a, c} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s: %v, want it kept", path, err)
+		}
+	}
+	if _, size := g.Stats(); size > total {
+		t.Errorf("cache size = %d, want at most %d", size, total)
+	}
+}
+
+func TestGeneratorFinishesAfterRequesterCancels(t *testing.T) {
+	g, err := NewGenerator(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 生成の順番待ちの間に最初のリクエストがキャンセルされる
+	for i := 0; i < cap(g.sem); i++ {
+		g.sem <- struct{}{}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	type result struct {
+		f   *os.File
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		f, err := g.Open(ctx, Key{Hash: testHash, Width: 40, Height: 40, Format: "image/png"}, testPNG(t, 100, 50))
+		done <- result{f, err}
+	}()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		g.mu.Lock()
+		n := len(g.inflight)
+		g.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("generation did not start")
+		}
+	}
+	cancel()
+	for i := 0; i < cap(g.sem); i++ {
+		<-g.sem
+	}
+
+	// 待っている他のリクエストのため、生成は最後まで続ける
+	r := <-done
+	if r.err != nil {
+		t.Fatalf("Open() after cancel = %v, want the generated thumbnail", r.err)
+	}
+	r.f.Close()
+	if n, _ := g.Stats(); n != 1 {
+		t.Errorf("cached files = %d, want 1", n)
+	}
+}
@@ -0,0 +1,82 @@
+package thumbnail
+
+import (
+	"strconv"
+	"strings"
+)
+
+// 縮小画像として出力できる形式
+const (
+	FormatPNG  = "image/png"
+	FormatJPEG = "image/jpeg"
+)
+
+var formatExt = map[string]string{
+	FormatPNG:  "png",
+	FormatJPEG: "jpg",
+}
+
+// Accept ヘッダーから出力形式を選ぶ
+// 同じ q 値なら元の画像の形式を優先する（GIF などは PNG として扱う）。
+// 受け入れられる形式がない場合は ok=false を返す
+func Negotiate(accept, srcContentType string) (format string, ok bool) {
+	preferred := FormatPNG
+	if srcContentType == FormatJPEG {
+		preferred = FormatJPEG
+	}
+	if strings.TrimSpace(accept) == "" {
+		return preferred, true
+	}
+
+	candidates := []string{preferred, FormatPNG, FormatJPEG}
+	best, bestQ := "", 0.0
+	for _, c := range candidates {
+		if q := quality(accept, c); q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	return best, best != ""
+}
+
+// Accept ヘッダーのうち、mediaType に最も具体的に一致する範囲の q 値を返す
+func quality(accept, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		r := strings.ToLower(strings.TrimSpace(params[0]))
+
+		s := -1
+		switch r {
+		case mediaType:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+		if s <= specificity {
+			continue
+		}
+
+		v := 1.0
+		for _, p := range params[1:] {
+			k, val, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.ToLower(k) != "q" {
+				continue
+			}
+			f, err := strconv.ParseFloat(val, 64)
+			if err != nil || f < 0 || f > 1 {
+				f = 0
+			}
+			v = f
+		}
+		q, specificity = v, s
+	}
+	return q
+}
+
+// 出力形式に対応する拡張子
+func Ext(format string) string {
+	return formatExt[format]
+}
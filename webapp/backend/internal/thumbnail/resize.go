@@ -0,0 +1,118 @@
+package thumbnail
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// 縦横比を保ったまま w×h に収まる大きさを求める
+// w か h の一方が 0 の場合は、もう一方に合わせる。元の画像より大きくはしない
+func Fit(srcW, srcH, w, h int) (int, int) {
+	if srcW <= 0 || srcH <= 0 {
+		return 0, 0
+	}
+	scale := 1.0
+	if w > 0 {
+		scale = math.Min(scale, float64(w)/float64(srcW))
+	}
+	if h > 0 {
+		scale = math.Min(scale, float64(h)/float64(srcH))
+	}
+	dw := int(math.Round(float64(srcW) * scale))
+	dh := int(math.Round(float64(srcH) * scale))
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+	return dw, dh
+}
+
+// 画像を dw×dh に縮小する（面積平均法）
+// 縮小後の各画素に重なる元の画素を、重なる面積で重み付けして平均する。
+// 透過を正しく混ぜるため、乗算済みアルファの RGBA で計算する
+func Resize(src image.Image, dw, dh int) *image.RGBA {
+	b := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || rgba.Rect.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Rect, src, b.Min, draw.Src)
+	}
+	sw, sh := rgba.Rect.Dx(), rgba.Rect.Dy()
+
+	xWeights := areaWeights(sw, dw)
+	yWeights := areaWeights(sh, dh)
+
+	// 横方向に縮小した中間結果（sh 行 × dw 列）
+	tmp := make([]float64, sh*dw*4)
+	for y := 0; y < sh; y++ {
+		row := rgba.Pix[y*rgba.Stride:]
+		for x, ws := range xWeights {
+			var r, g, bl, a float64
+			for _, w := range ws {
+				p := row[w.index*4:]
+				r += float64(p[0]) * w.weight
+				g += float64(p[1]) * w.weight
+				bl += float64(p[2]) * w.weight
+				a += float64(p[3]) * w.weight
+			}
+			t := tmp[(y*dw+x)*4:]
+			t[0], t[1], t[2], t[3] = r, g, bl, a
+		}
+	}
+
+	// 縦方向に縮小する
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y, ws := range yWeights {
+		out := dst.Pix[y*dst.Stride:]
+		for x := 0; x < dw; x++ {
+			var r, g, bl, a float64
+			for _, w := range ws {
+				t := tmp[(w.index*dw+x)*4:]
+				r += t[0] * w.weight
+				g += t[1] * w.weight
+				bl += t[2] * w.weight
+				a += t[3] * w.weight
+			}
+			p := out[x*4:]
+			p[0], p[1], p[2], p[3] = clamp8(r), clamp8(g), clamp8(bl), clamp8(a)
+		}
+	}
+	return dst
+}
+
+type sampleWeight struct {
+	index  int
+	weight float64
+}
+
+// 縮小後の各画素について、重なる元の画素とその重み（合計 1）を求める
+func areaWeights(srcSize, dstSize int) [][]sampleWeight {
+	scale := float64(srcSize) / float64(dstSize)
+	weights := make([][]sampleWeight, dstSize)
+	for i := range weights {
+		start := float64(i) * scale
+		end := start + scale
+		for j := int(start); j < srcSize && float64(j) < end; j++ {
+			overlap := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+			if overlap <= 0 {
+				continue
+			}
+			weights[i] = append(weights[i], sampleWeight{index: j, weight: overlap / scale})
+		}
+	}
+	return weights
+}
+
+func clamp8(v float64) uint8 {
+	v = math.Round(v)
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
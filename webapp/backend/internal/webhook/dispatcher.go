@@ -0,0 +1,244 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+const (
+	defaultPollInterval = 1 * time.Second
+	defaultBatchSize    = 100
+	defaultConcurrency  = 8
+	// 送信を諦めて failed にするまでの試行回数
+	defaultMaxAttempts = 8
+	// 再送間隔は baseBackoff から倍々に伸ばし、maxBackoff で頭打ちにする
+	defaultBaseBackoff = 5 * time.Second
+	defaultMaxBackoff  = 1 * time.Hour
+	// 1件の送信（応答の読み込みまで）にかける時間の上限
+	defaultSendTimeout = 10 * time.Second
+	// 送信中の配送を他のインスタンスが取得しないよう次回送信時刻をずらす時間は、
+	// 取得した配送をすべて送り終えるまでの最長時間にこの時間を加えたものにする
+	leaseMargin = 30 * time.Second
+	// ログに残す応答本文の最大長
+	maxResponseSnippet = 512
+)
+
+// 通知先ごとの配送を送信する（配送は Sink がドメインイベントから作成する）
+// 送信時刻を迎えた配送を署名付きで POST する。
+// 2xx 以外の応答や通信エラーは指数バックオフで再送する
+type Dispatcher struct {
+	store        *repository.Store
+	client       *http.Client
+	PollInterval time.Duration
+	BatchSize    int
+	Concurrency  int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	SendTimeout  time.Duration
+}
+
+// client が nil の場合は http.DefaultClient を使う（送信ごとに SendTimeout で打ち切る）
+func NewDispatcher(store *repository.Store, client *http.Client) *Dispatcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Dispatcher{
+		store:        store,
+		client:       client,
+		PollInterval: defaultPollInterval,
+		BatchSize:    defaultBatchSize,
+		Concurrency:  defaultConcurrency,
+		MaxAttempts:  defaultMaxAttempts,
+		BaseBackoff:  defaultBaseBackoff,
+		MaxBackoff:   defaultMaxBackoff,
+		SendTimeout:  defaultSendTimeout,
+	}
+}
+
+// ctx がキャンセルされるまで配送を続ける
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.deliverDue(ctx); err != nil {
+				slog.Error("Failed to deliver webhooks", "error", err)
+			}
+		}
+	}
+}
+
+// 送信時刻を迎えた配送を取得して送信する
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	var jobs []model.WebhookDeliveryJob
+	err := d.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		var err error
+		now := time.Now()
+		jobs, err = txStore.WebhookRepo.ClaimDueDeliveries(ctx, now, d.BatchSize)
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+		ids := make([]int64, len(jobs))
+		for i, job := range jobs {
+			ids[i] = job.DeliveryID
+		}
+		return txStore.WebhookRepo.LeaseDeliveries(ctx, ids, now.Add(d.lease(len(jobs))))
+	})
+	if err != nil {
+		return err
+	}
+
+	sem := make(chan struct{}, d.Concurrency)
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(job model.WebhookDeliveryJob) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			d.deliver(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+	return nil
+}
+
+// n 件の配送を送り終えるまで、他のインスタンスに取得させない時間
+// Concurrency 件ずつ送信し、それぞれが SendTimeout で打ち切られる場合の最長時間に余裕を加える
+func (d *Dispatcher) lease(n int) time.Duration {
+	rounds := (n + d.Concurrency - 1) / d.Concurrency
+	return time.Duration(rounds)*d.SendTimeout + leaseMargin
+}
+
+// 1件の配送を送信し、結果を記録する
+func (d *Dispatcher) deliver(ctx context.Context, job model.WebhookDeliveryJob) {
+	start := time.Now()
+	statusCode, sendErr := d.send(ctx, job)
+	duration := time.Since(start)
+
+	delivery := job.WebhookDelivery
+	delivery.Attempts++
+	attempt := model.WebhookDeliveryAttempt{
+		DeliveryID:  delivery.DeliveryID,
+		Attempt:     delivery.Attempts,
+		DurationMS:  duration.Milliseconds(),
+		AttemptedAt: start,
+	}
+	if statusCode != 0 {
+		attempt.StatusCode = &statusCode
+	}
+	if sendErr != nil {
+		msg := sendErr.Error()
+		attempt.Error = &msg
+	}
+	delivery.LastStatusCode = attempt.StatusCode
+	delivery.LastError = attempt.Error
+
+	switch {
+	case sendErr == nil:
+		delivery.Status = model.WebhookDeliverySucceeded
+	case delivery.Attempts >= d.MaxAttempts:
+		delivery.Status = model.WebhookDeliveryFailed
+		slog.Warn("Webhook delivery failed", "delivery_id", delivery.DeliveryID, "url", job.URL, "attempts", delivery.Attempts, "error", sendErr)
+	default:
+		delivery.Status = model.WebhookDeliveryPending
+		delivery.NextAttemptAt = time.Now().Add(d.backoff(delivery.Attempts))
+	}
+
+	// 停止中でも送信結果は記録する
+	if err := d.store.WebhookRepo.RecordAttempt(context.WithoutCancel(ctx), &delivery, attempt); err != nil {
+		slog.Error("Failed to record webhook delivery", "delivery_id", delivery.DeliveryID, "error", err)
+	}
+}
+
+// 通知先へ POST する
+// 2xx 以外の応答はエラーとして扱い、応答のステータスコードを併せて返す
+func (d *Dispatcher) send(ctx context.Context, job model.WebhookDeliveryJob) (int, error) {
+	body, err := json.Marshal(Payload{
+		ID:        job.EventID,
+		Type:      job.EventType,
+		CreatedAt: job.EventTime,
+		Data:      json.RawMessage(job.Payload),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.SendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, strconv.FormatInt(job.EventID, 10))
+	req.Header.Set(HeaderEvent, job.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(job.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseSnippet))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return resp.StatusCode, nil
+}
+
+// attempts 回失敗した後の再送までの待ち時間
+// 同時に失敗した配送が一斉に再送されないよう、最大 20% のジッターを加える
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.BaseBackoff
+	for i := 1; i < attempts && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.MaxBackoff {
+		wait = d.MaxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(wait)/5 + 1))
+	return wait + jitter
+}
+
+// 通知先に送信する本文
+type Payload struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// 通知先がイベント種別を購読しているかどうか
+// event_types が空の通知先はすべてのイベントを購読する
+func Subscribes(ep model.WebhookEndpoint, eventType string) bool {
+	if ep.EventTypes == "" {
+		return true
+	}
+	for _, t := range strings.Split(ep.EventTypes, ",") {
+		if strings.TrimSpace(t) == eventType {
+			return true
+		}
+	}
+	return false
+}
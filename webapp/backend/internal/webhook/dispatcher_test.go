@@ -0,0 +1,175 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"backend/internal/dbtest"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	sig := Sign("secret", 1700000000, body)
+	if !Verify("secret", 1700000000, body, sig) {
+		t.Fatalf("Verify rejected its own signature %s", sig)
+	}
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+		signature string
+	}{
+		{"other secret", "other", 1700000000, `{"id":1}`, sig},
+		{"other timestamp", "secret", 1700000001, `{"id":1}`, sig},
+		{"other body", "secret", 1700000000, `{"id":2}`, sig},
+		{"no prefix", "secret", 1700000000, `{"id":1}`, sig[len(signaturePrefix):]},
+	}
+	for _, tt := range tests {
+		if Verify(tt.secret, tt.timestamp, []byte(tt.body), tt.signature) {
+			t.Errorf("%s: Verify accepted the signature", tt.name)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{20, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		got := d.backoff(tt.attempts)
+		// 最大 20% のジッターが加わる
+		if got < tt.want || got > tt.want+tt.want/5 {
+			t.Errorf("backoff(%d) = %s, want %s to %s", tt.attempts, got, tt.want, tt.want+tt.want/5)
+		}
+	}
+}
+
+func TestLease(t *testing.T) {
+	d := &Dispatcher{Concurrency: 8, SendTimeout: 10 * time.Second}
+	// 100 件を 8 件ずつ送ると 13 回分かかる
+	if got, want := d.lease(100), 13*10*time.Second+leaseMargin; got != want {
+		t.Errorf("lease(100) = %s, want %s", got, want)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	db := dbtest.Open(t)
+	store := repository.NewStore(db)
+	ctx := context.Background()
+	const secret = "test-secret"
+
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	received := make(chan error, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		switch {
+		case err != nil:
+			received <- err
+		case !Verify(secret, timestamp, body, r.Header.Get(HeaderSignature)):
+			received <- errors.New("signature mismatch")
+		default:
+			var p Payload
+			received <- json.Unmarshal(body, &p)
+		}
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer srv.Close()
+
+	ep := model.WebhookEndpoint{URL: srv.URL, Secret: secret, Active: true, CreatedAt: time.Now()}
+	endpointID, err := store.WebhookRepo.CreateEndpoint(ctx, &ep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	err = store.WebhookRepo.CreateDeliveries(ctx, []model.WebhookDeliveryJob{{
+		WebhookDelivery: model.WebhookDelivery{
+			EventID:       1,
+			EndpointID:    endpointID,
+			EventType:     model.WebhookEventOrderCreated,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		},
+		Payload:   []byte(`{"group_id":1}`),
+		EventTime: now,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDispatcher(store, srv.Client())
+	// 配送を読み直し、送信する形にする
+	job := func() model.WebhookDeliveryJob {
+		t.Helper()
+		deliveries, err := store.WebhookRepo.ListDeliveries(ctx, endpointID, 1)
+		if err != nil || len(deliveries) != 1 {
+			t.Fatalf("deliveries = %v, %v", deliveries, err)
+		}
+		return model.WebhookDeliveryJob{
+			WebhookDelivery: deliveries[0],
+			URL:             srv.URL,
+			Secret:          secret,
+			Payload:         []byte(`{"group_id":1}`),
+			EventTime:       now,
+		}
+	}
+
+	// 5xx は再送のため pending のまま、バックオフした時刻に次回送信する
+	before := time.Now()
+	d.deliver(ctx, job())
+	if err := <-received; err != nil {
+		t.Fatalf("receiver: %v", err)
+	}
+	got := job()
+	if got.Status != model.WebhookDeliveryPending || got.Attempts != 1 {
+		t.Fatalf("after 500: status = %s, attempts = %d, want pending, 1", got.Status, got.Attempts)
+	}
+	if got.LastStatusCode == nil || *got.LastStatusCode != http.StatusInternalServerError {
+		t.Errorf("last_status_code = %v, want 500", got.LastStatusCode)
+	}
+	// DATETIME の精度で切り捨てられても下回らないよう、1秒の余裕をみる
+	if got.NextAttemptAt.Before(before.Add(d.BaseBackoff - time.Second)) {
+		t.Errorf("next_attempt_at = %s, want at least %s after %s", got.NextAttemptAt, d.BaseBackoff, before)
+	}
+	attempts, err := store.WebhookRepo.ListAttempts(ctx, got.DeliveryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 1 || attempts[0].StatusCode == nil || *attempts[0].StatusCode != http.StatusInternalServerError {
+		t.Fatalf("attempts = %+v, want one attempt with status 500", attempts)
+	}
+
+	// 2xx で succeeded になる
+	status.Store(http.StatusNoContent)
+	d.deliver(ctx, got)
+	if err := <-received; err != nil {
+		t.Fatalf("receiver: %v", err)
+	}
+	got = job()
+	if got.Status != model.WebhookDeliverySucceeded || got.Attempts != 2 {
+		t.Fatalf("after 204: status = %s, attempts = %d, want succeeded, 2", got.Status, got.Attempts)
+	}
+	if attempts, _ := store.WebhookRepo.ListAttempts(ctx, got.DeliveryID); len(attempts) != 2 {
+		t.Errorf("attempts = %d, want 2", len(attempts))
+	}
+}
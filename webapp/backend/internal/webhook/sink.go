@@ -0,0 +1,166 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+// ドメインイベントのリレーの配信先として、Webhook のイベントを通知先ごとの配送に展開する
+//   - order_group.created → order.created
+//   - order.status_changed（delivering への引き受け） → order.claimed
+//   - order.status_changed（completed への変更） → order.delivered
+//
+// 有効な通知先がない場合は何も書き込まない。
+// 同じイベントが再送されても、イベントと通知先の組ごとに配送は1件だけ作られる
+type Sink struct {
+	store *repository.Store
+}
+
+func NewSink(store *repository.Store) *Sink {
+	return &Sink{store: store}
+}
+
+func (s *Sink) Name() string {
+	return "webhook"
+}
+
+func (s *Sink) Publish(ctx context.Context, evs []model.DomainEvent) error {
+	endpoints, err := s.store.WebhookRepo.ListActiveEndpoints(ctx)
+	if err != nil || len(endpoints) == 0 {
+		return err
+	}
+	payloads, err := convert(evs, func(orderIDs []int64) (map[int64]int, error) {
+		return s.store.OrderRepo.GetOwnersByOrderIDs(ctx, orderIDs)
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var deliveries []model.WebhookDeliveryJob
+	for _, p := range payloads {
+		for _, ep := range endpoints {
+			if !Subscribes(ep, p.Type) {
+				continue
+			}
+			deliveries = append(deliveries, model.WebhookDeliveryJob{
+				WebhookDelivery: model.WebhookDelivery{
+					EventID:       p.ID,
+					EndpointID:    ep.EndpointID,
+					EventType:     p.Type,
+					NextAttemptAt: now,
+					CreatedAt:     now,
+				},
+				Payload:   p.Data,
+				EventTime: p.CreatedAt,
+			})
+		}
+	}
+	return s.store.WebhookRepo.CreateDeliveries(ctx, deliveries)
+}
+
+// order_group.created の内容（order.created としてそのまま送る）
+type orderGroupCreated struct {
+	GroupID  int64   `json:"group_id"`
+	UserID   int     `json:"user_id"`
+	OrderIDs []int64 `json:"order_ids"`
+}
+
+// order.status_changed の内容
+type orderStatusChanged struct {
+	OrderID   int64  `json:"order_id"`
+	OldStatus string `json:"old_status"`
+	NewStatus string `json:"new_status"`
+	RobotID   string `json:"robot_id"`
+}
+
+// order.claimed / order.delivered の内容
+type orderStatus struct {
+	OrderID   int64  `json:"order_id"`
+	UserID    int    `json:"user_id"`
+	OldStatus string `json:"old_status"`
+	NewStatus string `json:"new_status"`
+	RobotID   string `json:"robot_id,omitempty"`
+}
+
+// ステータス変更に対応する Webhook のイベント種別（通知しない変更は空文字）
+func statusEventType(oldStatus, newStatus string) string {
+	switch {
+	case oldStatus == model.StatusShipping && newStatus == model.StatusDelivering:
+		return model.WebhookEventOrderClaimed
+	case oldStatus != model.StatusCompleted && newStatus == model.StatusCompleted:
+		return model.WebhookEventOrderDelivered
+	}
+	return ""
+}
+
+// ドメインイベントを Webhook で送る内容に変換する
+// owners は注文IDから注文したユーザーIDを求める。通知しないイベントは読み飛ばす
+func convert(evs []model.DomainEvent, owners func(orderIDs []int64) (map[int64]int, error)) ([]Payload, error) {
+	changes := make(map[int64]orderStatusChanged)
+	var orderIDs []int64
+	for _, ev := range evs {
+		if ev.EventType != model.DomainEventOrderStatusChanged {
+			continue
+		}
+		var c orderStatusChanged
+		if err := json.Unmarshal(ev.Payload, &c); err != nil {
+			// 読めないイベントで配信全体を止めないよう読み飛ばす
+			slog.Warn("Skipped malformed domain event", "event_id", ev.EventID, "error", err)
+			continue
+		}
+		if statusEventType(c.OldStatus, c.NewStatus) == "" {
+			continue
+		}
+		changes[ev.EventID] = c
+		orderIDs = append(orderIDs, c.OrderID)
+	}
+	userIDs := map[int64]int{}
+	if len(orderIDs) > 0 {
+		var err error
+		if userIDs, err = owners(orderIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	var payloads []Payload
+	for _, ev := range evs {
+		var data interface{}
+		var eventType string
+		switch ev.EventType {
+		case model.DomainEventOrderGroupCreated:
+			var g orderGroupCreated
+			if err := json.Unmarshal(ev.Payload, &g); err != nil {
+				slog.Warn("Skipped malformed domain event", "event_id", ev.EventID, "error", err)
+				continue
+			}
+			eventType, data = model.WebhookEventOrderCreated, g
+		case model.DomainEventOrderStatusChanged:
+			c, ok := changes[ev.EventID]
+			if !ok {
+				continue
+			}
+			eventType = statusEventType(c.OldStatus, c.NewStatus)
+			data = orderStatus{
+				OrderID:   c.OrderID,
+				UserID:    userIDs[c.OrderID],
+				OldStatus: c.OldStatus,
+				NewStatus: c.NewStatus,
+				RobotID:   c.RobotID,
+			}
+		default:
+			continue
+		}
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, Payload{ID: ev.EventID, Type: eventType, CreatedAt: ev.OccurredAt, Data: b})
+	}
+	return payloads, nil
+}
@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"backend/internal/dbtest"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+func TestConvert(t *testing.T) {
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	ev := func(id int64, eventType, payload string) model.DomainEvent {
+		return model.DomainEvent{EventID: id, EventType: eventType, Payload: []byte(payload), OccurredAt: at}
+	}
+	evs := []model.DomainEvent{
+		ev(1, model.DomainEventOrderCreated, `{"order_id":10,"user_id":5,"product_id":1,"group_id":3,"shipped_status":"shipping"}`),
+		ev(2, model.DomainEventOrderGroupCreated, `{"group_id":3,"user_id":5,"order_ids":[10]}`),
+		ev(3, model.DomainEventOrderStatusChanged, `{"order_id":10,"old_status":"shipping","new_status":"delivering","robot_id":"robot-1"}`),
+		ev(4, model.DomainEventOrderStatusChanged, `{"order_id":11,"old_status":"shipping","new_status":"cancelled"}`),
+		ev(5, model.DomainEventOrderStatusChanged, `{"order_id":10,"old_status":"delivering","new_status":"completed","robot_id":"robot-1"}`),
+		ev(6, model.DomainEventOrderStatusChanged, `{"order_id":10,"old_status":"completed","new_status":"completed"}`),
+	}
+
+	var looked []int64
+	payloads, err := convert(evs, func(orderIDs []int64) (map[int64]int, error) {
+		looked = append(looked, orderIDs...)
+		return map[int64]int{10: 5}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(looked) != 2 {
+		t.Errorf("owners looked up for %v, want only the claimed and delivered orders", looked)
+	}
+
+	want := []struct {
+		id        int64
+		eventType string
+		data      string
+	}{
+		{2, model.WebhookEventOrderCreated, `{"group_id":3,"user_id":5,"order_ids":[10]}`},
+		{3, model.WebhookEventOrderClaimed, `{"order_id":10,"user_id":5,"old_status":"shipping","new_status":"delivering","robot_id":"robot-1"}`},
+		{5, model.WebhookEventOrderDelivered, `{"order_id":10,"user_id":5,"old_status":"delivering","new_status":"completed","robot_id":"robot-1"}`},
+	}
+	if len(payloads) != len(want) {
+		t.Fatalf("got %d payloads, want %d: %+v", len(payloads), len(want), payloads)
+	}
+	for i, w := range want {
+		p := payloads[i]
+		if p.ID != w.id || p.Type != w.eventType || !p.CreatedAt.Equal(at) {
+			t.Errorf("payload %d = {%d %s %v}, want {%d %s %v}", i, p.ID, p.Type, p.CreatedAt, w.id, w.eventType, at)
+		}
+		if !jsonEqual(t, p.Data, w.data) {
+			t.Errorf("payload %d data = %s, want %s", i, p.Data, w.data)
+		}
+	}
+}
+
+func TestConvertSkipsOwnerLookupWithoutStatusWebhooks(t *testing.T) {
+	evs := []model.DomainEvent{
+		{EventID: 1, EventType: model.DomainEventOrderStatusChanged, Payload: []byte(`{"order_id":1,"old_status":"shipping","new_status":"cancelled"}`)},
+		{EventID: 2, EventType: model.DomainEventOrderStatusChanged, Payload: []byte(`not json`)},
+	}
+	payloads, err := convert(evs, func([]int64) (map[int64]int, error) {
+		t.Fatal("owners should not be looked up")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payloads) != 0 {
+		t.Fatalf("payloads = %+v, want none", payloads)
+	}
+}
+
+func TestSinkPublish(t *testing.T) {
+	db := dbtest.Open(t)
+	store := repository.NewStore(db)
+	sink := NewSink(store)
+	ctx := context.Background()
+	evs := []model.DomainEvent{{
+		EventID:    1,
+		EventType:  model.DomainEventOrderGroupCreated,
+		Payload:    []byte(`{"group_id":1,"user_id":1,"order_ids":[1]}`),
+		OccurredAt: time.Now(),
+	}}
+	countDeliveries := func() int {
+		var n int
+		if err := db.Get(&n, "SELECT COUNT(*) FROM webhook_deliveries"); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	// 通知先がなければ配送は作らない
+	if err := sink.Publish(ctx, evs); err != nil {
+		t.Fatal(err)
+	}
+	if n := countDeliveries(); n != 0 {
+		t.Fatalf("deliveries = %d without endpoints, want 0", n)
+	}
+
+	for _, ep := range []model.WebhookEndpoint{
+		{URL: "http://a.example/", Secret: "s", Active: true, CreatedAt: time.Now()},
+		{URL: "http://b.example/", Secret: "s", EventTypes: model.WebhookEventOrderDelivered, Active: true, CreatedAt: time.Now()},
+	} {
+		if _, err := store.WebhookRepo.CreateEndpoint(ctx, &ep); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 再送されても配送は重複しない
+	for i := 0; i < 2; i++ {
+		if err := sink.Publish(ctx, evs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := countDeliveries(); n != 1 {
+		t.Fatalf("deliveries = %d, want 1 for the endpoint subscribed to order.created", n)
+	}
+
+	var d struct {
+		EventID   int64  `db:"event_id"`
+		EventType string `db:"event_type"`
+		Payload   []byte `db:"payload"`
+	}
+	if err := db.Get(&d, "SELECT event_id, event_type, payload FROM webhook_deliveries"); err != nil {
+		t.Fatal(err)
+	}
+	if d.EventID != 1 || d.EventType != model.WebhookEventOrderCreated {
+		t.Fatalf("delivery = {%d %s}, want the order.created delivery for event 1", d.EventID, d.EventType)
+	}
+	if !jsonEqual(t, d.Payload, `{"group_id":1,"user_id":1,"order_ids":[1]}`) {
+		t.Errorf("payload = %s", d.Payload)
+	}
+}
+
+func jsonEqual(t *testing.T, got json.RawMessage, want string) bool {
+	t.Helper()
+	var a, b interface{}
+	if err := json.Unmarshal(got, &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &b); err != nil {
+		t.Fatal(err)
+	}
+	ab, _ := json.Marshal(a)
+	bb, _ := json.Marshal(b)
+	return string(ab) == string(bb)
+}
@@ -23,7 +23,7 @@ import {
 } from "@mui/material";
 import { useRouter } from "next/navigation";
 
-type ShippedStatus = "completed" | "delivering" | "shipping";
+type ShippedStatus = "completed" | "delivering" | "shipping" | "cancelled";
 
 type OrdersRow = {
   id: number;
@@ -192,6 +192,8 @@ export default function OrdersPage() {
         return <Chip label="配送中" color="primary" size="small" />;
       case "shipping":
         return <Chip label="出荷準備" color="default" size="small" />;
+      case "cancelled":
+        return <Chip label="キャンセル済み" color="warning" size="small" />;
       default:
         return <Chip label="不明" color="default" size="small" />;
     }
@@ -0,0 +1,21 @@
+-- 同じユーザーに一緒に購入された商品の類似度（定期的に再計算する）
+-- score は購入したユーザー集合のコサイン類似度
+CREATE TABLE product_similarities (
+    product_id INT UNSIGNED NOT NULL,
+    related_product_id INT UNSIGNED NOT NULL,
+    score DOUBLE NOT NULL,
+    co_purchases INT UNSIGNED NOT NULL,
+    computed_at DATETIME NOT NULL,
+    PRIMARY KEY (product_id, related_product_id),
+    INDEX idx_product_similarities_score (product_id, score)
+);
+
+-- 購入履歴のないユーザー向けの人気商品（類似度と一緒に再計算する）
+-- score は商品を購入したユーザーの割合
+CREATE TABLE product_popularity (
+    product_id INT UNSIGNED PRIMARY KEY,
+    buyer_count INT UNSIGNED NOT NULL,
+    score DOUBLE NOT NULL,
+    computed_at DATETIME NOT NULL,
+    INDEX idx_product_popularity_score (score)
+);
@@ -0,0 +1,17 @@
+-- 内容のハッシュ(SHA-256)で管理する画像
+-- 同じ内容の画像は1つだけ保存し、パスからハッシュを引いて配信する
+CREATE TABLE image_objects (
+    hash CHAR(64) PRIMARY KEY,
+    size BIGINT UNSIGNED NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+-- 画像のパス（products.image）と内容のハッシュの対応
+CREATE TABLE image_paths (
+    path VARCHAR(512) PRIMARY KEY,
+    hash CHAR(64) NOT NULL,
+    updated_at DATETIME NOT NULL,
+    INDEX idx_image_paths_hash (hash),
+    FOREIGN KEY (hash) REFERENCES image_objects(hash)
+);
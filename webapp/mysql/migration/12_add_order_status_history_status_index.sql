@@ -0,0 +1,3 @@
+-- 配達予定時刻の推定で、引き受け〜配達の所要時間をステータスごとの履歴を結合して読むためのインデックス
+ALTER TABLE order_status_history
+    ADD INDEX idx_order_status_history_status_order (new_status, order_id);
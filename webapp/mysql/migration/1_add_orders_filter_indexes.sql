@@ -0,0 +1,6 @@
+-- 注文履歴の絞り込み（ステータス・日時範囲・商品ID）用の複合インデックス
+ALTER TABLE orders
+    ADD INDEX idx_orders_user_status_created (user_id, shipped_status, created_at),
+    ADD INDEX idx_orders_user_created (user_id, created_at),
+    ADD INDEX idx_orders_user_arrived (user_id, arrived_at),
+    ADD INDEX idx_orders_user_product (user_id, product_id);
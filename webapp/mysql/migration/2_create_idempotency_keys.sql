@@ -0,0 +1,14 @@
+-- 注文作成APIの Idempotency-Key を保存し、再送時に最初の結果を返すためのテーブル
+CREATE TABLE idempotency_keys (
+    user_id INT UNSIGNED NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    status_code INT,
+    order_ids JSON,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, idempotency_key),
+    INDEX idx_idempotency_keys_expires_at (expires_at),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
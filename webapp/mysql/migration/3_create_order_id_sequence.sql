@@ -0,0 +1,9 @@
+-- 注文IDの採番用テーブル
+-- バルクインサート時に LastInsertId() からの計算に頼らず、確保した範囲のIDを明示して挿入する
+CREATE TABLE order_id_sequence (
+    id TINYINT UNSIGNED NOT NULL PRIMARY KEY,
+    last_id BIGINT UNSIGNED NOT NULL
+);
+
+INSERT INTO order_id_sequence (id, last_id)
+SELECT 1, COALESCE(MAX(order_id), 0) FROM orders;
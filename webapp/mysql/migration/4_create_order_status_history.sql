@@ -0,0 +1,12 @@
+-- 注文ステータスの変更履歴
+-- old_status が NULL の行は注文作成を表す
+CREATE TABLE order_status_history (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    order_id INT UNSIGNED NOT NULL,
+    old_status VARCHAR(50),
+    new_status VARCHAR(50) NOT NULL,
+    actor VARCHAR(100) NOT NULL,
+    changed_at DATETIME(6) NOT NULL,
+    INDEX idx_order_status_history_order (order_id, changed_at),
+    FOREIGN KEY (order_id) REFERENCES orders(order_id) ON DELETE CASCADE
+);
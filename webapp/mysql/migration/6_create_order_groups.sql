@@ -0,0 +1,17 @@
+-- 1回の購入（注文作成・カートの購入手続き）で作られた注文をまとめるグループ
+CREATE TABLE order_groups (
+    group_id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    created_at DATETIME NOT NULL,
+    INDEX idx_order_groups_user (user_id, group_id),
+    FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- 既存の注文はグループに属さない(NULL)
+ALTER TABLE orders
+    ADD COLUMN group_id BIGINT UNSIGNED NULL,
+    ADD INDEX idx_orders_group (group_id);
+
+-- Idempotency-Key の再送時にもグループIDを返せるようにする
+ALTER TABLE idempotency_keys
+    ADD COLUMN group_id BIGINT UNSIGNED NULL;
@@ -0,0 +1,49 @@
+-- 注文のライフサイクルイベントを外部システムへ通知する Webhook
+
+-- 通知先
+-- event_types はカンマ区切りで購読するイベント種別を指定する（空の場合はすべて）
+CREATE TABLE webhook_endpoints (
+    endpoint_id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    event_types VARCHAR(255) NOT NULL DEFAULT '',
+    active TINYINT(1) NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL
+);
+
+-- 通知先ごとの配送状況
+-- ドメインイベントのリレーが、イベントを購読している通知先ごとに作成する。
+-- event_id は domain_events の event_id を指す
+-- （ドメインイベントは保持期間を過ぎると削除されるため、送信する内容を配送に持たせ、外部キーは張らない）
+CREATE TABLE webhook_deliveries (
+    delivery_id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    event_id BIGINT UNSIGNED NOT NULL,
+    endpoint_id BIGINT UNSIGNED NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSON NOT NULL,
+    event_created_at DATETIME(6) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INT UNSIGNED NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME(6) NOT NULL,
+    last_status_code INT,
+    last_error TEXT,
+    created_at DATETIME(6) NOT NULL,
+    updated_at DATETIME(6) NOT NULL,
+    UNIQUE KEY uq_webhook_deliveries_event_endpoint (event_id, endpoint_id),
+    INDEX idx_webhook_deliveries_due (status, next_attempt_at),
+    INDEX idx_webhook_deliveries_finished (status, updated_at),
+    FOREIGN KEY (endpoint_id) REFERENCES webhook_endpoints(endpoint_id) ON DELETE CASCADE
+);
+
+-- 配送試行ごとのログ
+CREATE TABLE webhook_delivery_attempts (
+    attempt_id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    delivery_id BIGINT UNSIGNED NOT NULL,
+    attempt INT UNSIGNED NOT NULL,
+    status_code INT,
+    error TEXT,
+    duration_ms INT UNSIGNED NOT NULL,
+    attempted_at DATETIME(6) NOT NULL,
+    INDEX idx_webhook_delivery_attempts_delivery (delivery_id, attempt),
+    FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries(delivery_id) ON DELETE CASCADE
+);
@@ -0,0 +1,18 @@
+-- 注文の状態変更を記録するドメインイベントのアウトボックス
+-- 変更と同じトランザクションで書き込み、リレーが各配信先へ送る
+CREATE TABLE domain_events (
+    event_id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    aggregate_type VARCHAR(50) NOT NULL,
+    aggregate_id BIGINT UNSIGNED NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSON NOT NULL,
+    occurred_at DATETIME(6) NOT NULL,
+    INDEX idx_domain_events_aggregate (aggregate_type, aggregate_id, event_id)
+);
+
+-- 配信先ごとの送信済みイベントの位置
+CREATE TABLE domain_event_offsets (
+    consumer VARCHAR(100) PRIMARY KEY,
+    last_event_id BIGINT UNSIGNED NOT NULL,
+    updated_at DATETIME(6) NOT NULL
+);
@@ -0,0 +1,3 @@
+-- 配達予定時刻の推定で、配達済みの注文を到着日時の新しい順に読むためのインデックス
+ALTER TABLE orders
+    ADD INDEX idx_orders_status_arrived (shipped_status, arrived_at);